@@ -13,6 +13,7 @@ Documentation=https://github.com/d3m0k1d/BanForge
 [Service]
 Type=simple
 ExecStart=/usr/bin/banforge daemon
+ExecReload=/bin/kill -HUP $MAINPID
 User=root
 Group=root
 Restart=always
@@ -43,6 +44,7 @@ command_args="daemon"
 
 pidfile="/run/${RC_SVCNAME}.pid"
 command_background="yes"
+extra_started_commands="reload"
 
 depend() {
   need net
@@ -56,6 +58,12 @@ start_post() {
 stop_post() {
   einfo "BanForge is now stopped"
 }
+
+reload() {
+  ebegin "Reloading ${RC_SVCNAME} configuration"
+  start-stop-daemon --signal HUP --pidfile "${pidfile}"
+  eend $?
+}
 EOF
   chmod 755 /etc/init.d/banforge
   rc-update add banforge
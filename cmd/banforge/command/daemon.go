@@ -6,20 +6,24 @@ import (
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/d3m0k1d/BanForge/internal/blocker"
 	"github.com/d3m0k1d/BanForge/internal/config"
 	"github.com/d3m0k1d/BanForge/internal/judge"
 	"github.com/d3m0k1d/BanForge/internal/logger"
 	"github.com/d3m0k1d/BanForge/internal/metrics"
-	"github.com/d3m0k1d/BanForge/internal/parser"
 	"github.com/d3m0k1d/BanForge/internal/storage"
 	"github.com/spf13/cobra"
 )
 
+var daemonDryRun bool
+
 var DaemonCmd = &cobra.Command{
 	Use:   "daemon",
 	Short: "Run BanForge daemon process",
+	Long: "Run the BanForge daemon. With --dry-run, or mode = \"monitor\" in config.toml, " +
+		"bans are recorded, logged and counted but never applied to the firewall.",
 	Run: func(cmd *cobra.Command, args []string) {
 		entryCh := make(chan *storage.LogEntry, 1000)
 		resultCh := make(chan *storage.LogEntry, 100)
@@ -27,6 +31,10 @@ var DaemonCmd = &cobra.Command{
 		defer stop()
 		log := logger.New(false)
 		log.Info("Starting BanForge daemon")
+		if err := storage.CreateTables(); err != nil {
+			log.Error("Failed to migrate databases", "error", err)
+			os.Exit(1)
+		}
 		reqDb_w, err := storage.NewRequestsWr()
 		if err != nil {
 			log.Error("Failed to create request writer", "error", err)
@@ -47,6 +55,11 @@ var DaemonCmd = &cobra.Command{
 			log.Error("Failed to create ban writter", "error", err)
 			os.Exit(1)
 		}
+		positions, err := storage.NewPositionStore()
+		if err != nil {
+			log.Error("Failed to create position store", "error", err)
+			os.Exit(1)
+		}
 		defer func() {
 			err = banDb_r.Close()
 			if err != nil {
@@ -62,6 +75,10 @@ var DaemonCmd = &cobra.Command{
 			log.Error("Failed to load config", "error", err)
 			os.Exit(1)
 		}
+		if err := checkParsers(cfg.Service); err != nil {
+			log.Error("Invalid service configuration", "error", err)
+			os.Exit(1)
+		}
 		retention, err := config.ParseDurationWithYears(cfg.Storage.RetentionTime)
 		if err != nil {
 			log.Error("Failed to parse request retention", "error", err)
@@ -91,20 +108,30 @@ var DaemonCmd = &cobra.Command{
 			log.Error("Failed to create firewall blocker", "error", err)
 			os.Exit(1)
 		}
-		err = b.Setup(cfg.Firewall.Config)
-		if err != nil {
-			log.Error("Failed to setup firewall", "error", err)
-			os.Exit(1)
-		}
-		ips, err := banDb_r.RestoreBans()
-		if err != nil {
-			log.Error("Failed to restore bans", "error", err)
-			os.Exit(1)
-		}
-		for _, ip := range ips {
-			err = b.Ban(ip)
+		monitor := daemonDryRun || cfg.Mode == config.ModeMonitor
+		if monitor {
+			// Leave the firewall as it is: no setup and no restored bans.
+			log.Warn("Running in monitor mode, bans will not be applied to the firewall")
+		} else {
+			err = b.Setup(cfg.Firewall.Config)
+			if err != nil {
+				log.Error("Failed to setup firewall", "error", err)
+				os.Exit(1)
+			}
+			ignoreIP, err := config.NewIPMatcher(cfg.IgnoreIP)
 			if err != nil {
-				log.Error("Failed to ban ip", "ip", ip, "error", err)
+				log.Error("Invalid ignore_ip", "error", err)
+				os.Exit(1)
+			}
+			bans, err := banDb_r.ActiveBans()
+			if err != nil {
+				log.Error("Failed to restore bans", "error", err)
+				os.Exit(1)
+			}
+			for _, ip := range restoreBans(log, b, bans, ignoreIP) {
+				if err := banDb_w.RecordRestore(ip); err != nil {
+					log.Error("Failed to record restored ban", "ip", ip, "error", err)
+				}
 			}
 		}
 		r, err := config.LoadRuleConfig()
@@ -113,10 +140,16 @@ var DaemonCmd = &cobra.Command{
 			os.Exit(1)
 		}
 		j := judge.New(banDb_r, banDb_w, reqDb_r, b, resultCh, entryCh)
-		j.LoadRules(r)
+		j.SetMonitor(monitor)
+		if err := j.Reload(cfg, r); err != nil {
+			log.Error("Failed to load rules", "error", err)
+			os.Exit(1)
+		}
+		if err := j.WarmUp(); err != nil {
+			log.Error("Failed to warm up retry counter", "error", err)
+		}
 		go j.UnbanChecker()
 
-		var parserWg sync.WaitGroup
 		var tribunalWg sync.WaitGroup
 		var writerWg sync.WaitGroup
 
@@ -131,105 +164,71 @@ var DaemonCmd = &cobra.Command{
 			defer writerWg.Done()
 			storage.WriteReq(reqDb_w, resultCh)
 		}()
-		var scanners []*parser.Scanner
-
-		for _, svc := range cfg.Service {
-			log.Info(
-				"Processing service",
-				"name", svc.Name,
-				"enabled", svc.Enabled,
-				"path", svc.LogPath,
-			)
-
-			if !svc.Enabled {
-				log.Info("Service disabled, skipping", "name", svc.Name)
-				continue
-			}
 
-			log.Info("Starting parser for service", "name", svc.Name, "path", svc.LogPath)
-			if svc.Logging != "file" && svc.Logging != "journald" {
-				log.Error("Invalid logging type", "type", svc.Logging)
-				continue
-			}
+		services := newServiceManager(log, entryCh, positions)
+		services.Sync(cfg.Service)
 
-			if svc.Logging == "file" {
-				log.Info("Logging to file", "path", svc.LogPath)
-				pars, err := parser.NewScannerTail(svc.LogPath)
-				if err != nil {
-					log.Error("Failed to create scanner", "service", svc.Name, "error", err)
-					continue
-				}
+		reload := func() {
+			log.Info("Reloading configuration")
+			newCfg, err := config.LoadConfig()
+			if err != nil {
+				log.Error("Reload failed, keeping running configuration", "error", err)
+				metrics.IncError()
+				return
+			}
+			if err := checkParsers(newCfg.Service); err != nil {
+				log.Error("Reload failed, keeping running configuration", "error", err)
+				metrics.IncError()
+				return
+			}
+			newRules, err := config.LoadRuleConfig()
+			if err != nil {
+				log.Error("Reload failed, keeping running configuration", "error", err)
+				metrics.IncError()
+				return
+			}
+			if err := j.Reload(newCfg, newRules); err != nil {
+				log.Error("Reload failed, keeping running configuration", "error", err)
+				metrics.IncError()
+				return
+			}
+			warnRestartRequired(log, cfg, newCfg)
+			services.Sync(newCfg.Service)
+			cfg = newCfg
+			log.Info("Configuration reloaded", "rules", len(newRules), "services", len(newCfg.Service))
+		}
 
-				scanners = append(scanners, pars)
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		defer signal.Stop(hup)
 
-				go pars.Start()
+		rulesChanged, err := config.WatchRules(ctx, config.RulesDir, rulesWatchDebounce)
+		if err != nil {
+			log.Warn("Not watching rules directory, reload with SIGHUP instead", "error", err)
+		}
 
-				parserWg.Add(1)
-				go func(p *parser.Scanner, serviceName string) {
-					defer parserWg.Done()
-					if svc.Name == "nginx" {
-						log.Info("Starting nginx parser", "service", serviceName)
-						ng := parser.NewNginxParser()
-						ng.Parse(p.Events(), entryCh)
+	loop:
+		for {
+			select {
+			case <-ctx.Done():
+				break loop
+			case <-hup:
+				reload()
+			case _, ok := <-rulesChanged:
+				if !ok {
+					if ctx.Err() == nil {
+						log.Warn("Stopped watching rules directory", "path", config.RulesDir)
 					}
-					if svc.Name == "ssh" {
-						log.Info("Starting ssh parser", "service", serviceName)
-						ssh := parser.NewSshdParser()
-						ssh.Parse(p.Events(), entryCh)
-					}
-					if svc.Name == "apache" {
-						log.Info("Starting apache parser", "service", serviceName)
-						ap := parser.NewApacheParser()
-						ap.Parse(p.Events(), entryCh)
-					}
-				}(pars, svc.Name)
-				continue
-			}
-
-			if svc.Logging == "journald" {
-				log.Info("Logging to journald", "path", svc.LogPath)
-				pars, err := parser.NewScannerJournald(svc.LogPath)
-				if err != nil {
-					log.Error("Failed to create scanner", "service", svc.Name, "error", err)
+					rulesChanged = nil
 					continue
 				}
-
-				scanners = append(scanners, pars)
-
-				go pars.Start()
-				parserWg.Add(1)
-				go func(p *parser.Scanner, serviceName string) {
-					defer parserWg.Done()
-					if svc.Name == "nginx" {
-						log.Info("Starting nginx parser", "service", serviceName)
-						ng := parser.NewNginxParser()
-						ng.Parse(p.Events(), entryCh)
-
-					}
-					if svc.Name == "ssh" {
-						log.Info("Starting ssh parser", "service", serviceName)
-						ssh := parser.NewSshdParser()
-						ssh.Parse(p.Events(), entryCh)
-					}
-					if svc.Name == "apache" {
-						log.Info("Starting apache parser", "service", serviceName)
-						ap := parser.NewApacheParser()
-						ap.Parse(p.Events(), entryCh)
-					}
-
-				}(pars, svc.Name)
-				continue
+				log.Info("Rules directory changed", "path", config.RulesDir)
+				reload()
 			}
 		}
-
-		<-ctx.Done()
 		log.Info("Shutdown signal received")
 
-		for _, s := range scanners {
-			s.Stop()
-		}
-
-		parserWg.Wait()
+		services.StopAll()
 		close(entryCh)
 		tribunalWg.Wait()
 		close(resultCh)
@@ -241,6 +240,76 @@ var DaemonCmd = &cobra.Command{
 		if err := reqDb_r.Close(); err != nil {
 			log.Error("Failed to close request reader database", "error", err)
 		}
+		if err := positions.Close(); err != nil {
+			log.Error("Failed to close position store", "error", err)
+		}
 		log.Info("BanForge daemon stopped")
 	},
 }
+
+// rulesWatchDebounce is how long rules.d must stay quiet before a change is
+// applied, so a burst of rule edits triggers a single reload.
+const rulesWatchDebounce = time.Second
+
+// restoreBans re-applies active bans to the firewall and returns the
+// addresses it restored. A backend that expires bans itself gets all of them
+// at once, each with the time it has left.
+func restoreBans(
+	log *logger.Logger,
+	b blocker.BlockerEngine,
+	bans []storage.ActiveBan,
+	ignoreIP *config.IPMatcher,
+) []string {
+	timed := make([]blocker.TimedBan, 0, len(bans))
+	for _, ban := range bans {
+		if ignoreIP.Overlaps(ban.IP) {
+			log.Warn("Restore refused: IP is in ignore_ip", "ip", ban.IP)
+			metrics.IncBanRefused("restore")
+			continue
+		}
+		timed = append(timed, blocker.TimedBan{Target: ban.IP, Duration: time.Until(ban.ExpiresAt)})
+	}
+
+	if e, ok := b.(blocker.Expiring); ok && len(timed) > 0 {
+		err := e.BanFor(timed...)
+		if err == nil {
+			restored := make([]string, len(timed))
+			for i, ban := range timed {
+				restored[i] = ban.Target
+			}
+			return restored
+		}
+		log.Warn("Failed to restore bans at once, retrying one by one", "error", err)
+	}
+
+	var restored []string
+	for _, ban := range timed {
+		if err := blocker.BanFor(b, ban.Target, ban.Duration); err != nil {
+			log.Error("Failed to ban ip", "ip", ban.Target, "error", err)
+			continue
+		}
+		restored = append(restored, ban.Target)
+	}
+	return restored
+}
+
+// warnRestartRequired logs settings that changed on reload but are only
+// applied when the daemon starts.
+func warnRestartRequired(log *logger.Logger, old *config.Config, next *config.Config) {
+	if old.Firewall != next.Firewall {
+		log.Warn("Firewall settings changed, restart the daemon to apply them")
+	}
+	if old.Metrics != next.Metrics {
+		log.Warn("Metrics settings changed, restart the daemon to apply them")
+	}
+	if old.Storage != next.Storage {
+		log.Warn("Storage settings changed, restart the daemon to apply them")
+	}
+	if old.Mode != next.Mode {
+		log.Warn("Mode changed, restart the daemon to apply it")
+	}
+}
+
+func DaemonRegister() {
+	DaemonCmd.Flags().BoolVar(&daemonDryRun, "dry-run", false, "record bans without applying them to the firewall")
+}
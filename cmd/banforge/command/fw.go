@@ -1,8 +1,8 @@
 package command
 
 import (
+	"errors"
 	"fmt"
-	"net"
 	"os"
 
 	"github.com/d3m0k1d/BanForge/internal/blocker"
@@ -18,8 +18,8 @@ var (
 )
 
 var UnbanCmd = &cobra.Command{
-	Use:   "unban",
-	Short: "Unban IP",
+	Use:   "unban <ip|cidr>",
+	Short: "Unban IP or subnet",
 	Run: func(cmd *cobra.Command, args []string) {
 		err := func() error {
 			if len(args) == 0 {
@@ -45,17 +45,21 @@ var UnbanCmd = &cobra.Command{
 			if ip == "" {
 				return fmt.Errorf("IP can't be empty")
 			}
-			if net.ParseIP(ip) == nil {
-				return fmt.Errorf("invalid IP")
-			}
-			err = b.Unban(ip)
+			ip, err = blocker.NormalizeTarget(ip)
 			if err != nil {
 				return err
 			}
-			err = db.RemoveBan(ip)
-			if err != nil {
+			err = b.Unban(ip)
+			covered := errors.Is(err, blocker.ErrCovered)
+			if err != nil && !covered {
 				return err
 			}
+			if removeErr := db.RemoveBan(ip); removeErr != nil {
+				return removeErr
+			}
+			if covered {
+				return fmt.Errorf("ban removed, but the IP stays blocked: %w", err)
+			}
 			fmt.Println("IP unblocked successfully!")
 			return nil
 		}()
@@ -67,8 +71,8 @@ var UnbanCmd = &cobra.Command{
 }
 
 var BanCmd = &cobra.Command{
-	Use:   "ban",
-	Short: "Ban IP",
+	Use:   "ban <ip|cidr>",
+	Short: "Ban IP or subnet",
 	Run: func(cmd *cobra.Command, args []string) {
 		err := func() error {
 			if len(args) == 0 {
@@ -94,10 +98,22 @@ var BanCmd = &cobra.Command{
 			if ip == "" {
 				return fmt.Errorf("IP can't be empty")
 			}
-			if net.ParseIP(ip) == nil {
-				return fmt.Errorf("invalid IP")
+			ip, err = blocker.NormalizeTarget(ip)
+			if err != nil {
+				return err
+			}
+			ignoreIP, err := config.NewIPMatcher(cfg.IgnoreIP)
+			if err != nil {
+				return err
+			}
+			if ignoreIP.Overlaps(ip) {
+				return fmt.Errorf("IP %s is in ignore_ip, refusing to ban", ip)
+			}
+			duration, err := config.ParseDurationWithYears(ttl_fw)
+			if err != nil {
+				return fmt.Errorf("invalid duration: %w", err)
 			}
-			err = b.Ban(ip)
+			err = blocker.BanFor(b, ip, duration)
 			if err != nil {
 				return err
 			}
@@ -0,0 +1,111 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/d3m0k1d/BanForge/internal/config"
+	"github.com/d3m0k1d/BanForge/internal/storage"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyRule  string
+	historySince string
+	historyUntil string
+)
+
+var HistoryCmd = &cobra.Command{
+	Use:   "history [ip]",
+	Short: "Show ban and unban history",
+	Long: "Show ban and unban events. Filter by IP, rule and time range. " +
+		"--since and --until accept a duration back from now (e.g. 7d) or a date (2006-01-02).",
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := func() error {
+			var filter storage.HistoryFilter
+			if len(args) == 1 {
+				filter.IP = args[0]
+			}
+			filter.Rule = historyRule
+
+			var err error
+			if filter.Since, err = parseHistoryTime(historySince); err != nil {
+				return fmt.Errorf("invalid --since: %w", err)
+			}
+			if filter.Until, err = parseHistoryTime(historyUntil); err != nil {
+				return fmt.Errorf("invalid --until: %w", err)
+			}
+
+			d, err := storage.NewBanReader()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = d.Close()
+			}()
+
+			events, err := d.History(filter)
+			if err != nil {
+				return err
+			}
+			if len(events) == 0 {
+				fmt.Println("No history found")
+				return nil
+			}
+
+			// Restored bans repeat an earlier ban and monitor bans never
+			// reached the firewall, so neither counts as a ban.
+			bans, monitorBans := 0, 0
+			t := table.NewWriter()
+			t.SetOutputMirror(os.Stdout)
+			t.SetStyle(table.StyleBold)
+			t.AppendHeader(table.Row{"№", "IP", "Event", "Rule", "Source", "Time", "Expires At"})
+			for i, e := range events {
+				if e.Event == storage.EventBan {
+					switch e.Source {
+					case storage.SourceRestore:
+					case storage.SourceMonitor:
+						monitorBans++
+					default:
+						bans++
+					}
+				}
+				t.AppendRow(table.Row{i + 1, e.IP, e.Event, e.Rule, e.Source, e.CreatedAt, e.Expired})
+			}
+			t.AppendFooter(table.Row{"", "", "", "", "", "Bans", bans})
+			if monitorBans > 0 {
+				t.AppendFooter(table.Row{"", "", "", "", "", "Monitor bans", monitorBans})
+			}
+			t.Render()
+			return nil
+		}()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+// parseHistoryTime accepts a duration back from now in ban time format or an
+// absolute date. An empty string means no bound.
+func parseHistoryTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := config.ParseDurationWithYears(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func HistoryRegister() {
+	HistoryCmd.Flags().StringVarP(&historyRule, "rule", "r", "", "only events of this rule")
+	HistoryCmd.Flags().StringVarP(&historySince, "since", "s", "", "start of time range")
+	HistoryCmd.Flags().StringVarP(&historyUntil, "until", "u", "", "end of time range")
+}
@@ -0,0 +1,325 @@
+package command
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/d3m0k1d/BanForge/internal/config"
+	"github.com/d3m0k1d/BanForge/internal/judge"
+	"github.com/d3m0k1d/BanForge/internal/logger"
+	"github.com/d3m0k1d/BanForge/internal/parser"
+	"github.com/d3m0k1d/BanForge/internal/storage"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+// replayMaxLine matches the longest line the daemon reads from a log file.
+const replayMaxLine = 1024 * 1024
+
+var replayService string
+
+var ReplayCmd = &cobra.Command{
+	Use:   "replay <file>...",
+	Short: "Run log files through the rules without banning",
+	Long: "Feed existing log files (plain or gzip, - for stdin) through the parser of a service " +
+		"and the current rules, and report which IPs would have been banned, by which rule and when. " +
+		"Times are taken from the log lines, so find_time and ban_time behave as they would have live. " +
+		"The firewall, bans.db and rule actions are not touched.",
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		logger.Quiet()
+		if err := runReplay(replayService, args); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+// replayStats counts what a replay went through.
+type replayStats struct {
+	lines   int
+	entries int
+	hits    map[string]int
+	// backwards counts entries logged more than replayMaxSkew before an
+	// entry replayed earlier.
+	backwards int
+}
+
+// replayMaxSkew is how far log time may go back between lines before the
+// replay warns that its input is out of order. Lines of one log can be
+// written slightly out of order by concurrent workers.
+const replayMaxSkew = time.Minute
+
+// replaySniffLines is how many lines of a file are read at most to find its
+// first timestamp.
+const replaySniffLines = 1000
+
+func runReplay(serviceName string, files []string) error {
+	if serviceName == "" {
+		return fmt.Errorf("service name can't be empty (use -s flag)")
+	}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	rules, err := config.LoadRuleConfig()
+	if err != nil {
+		return err
+	}
+	// Notifications and scripts are for real bans only.
+	for i := range rules {
+		rules[i].Action = nil
+	}
+
+	p, err := parserFor(lookupService(cfg, serviceName))
+	if err != nil {
+		return fmt.Errorf("service %s: %w", serviceName, err)
+	}
+
+	banDb_w, banDb_r, err := storage.NewMemoryBanDB()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = banDb_w.Close()
+	}()
+	var now time.Time
+	banDb_w.SetClock(func() time.Time { return now })
+
+	stats := replayStats{hits: make(map[string]int)}
+	resultCh := make(chan *storage.LogEntry, 100)
+	hitsDone := make(chan struct{})
+	go func() {
+		defer close(hitsDone)
+		for hit := range resultCh {
+			stats.hits[hit.Rule]++
+		}
+	}()
+
+	j := judge.New(banDb_r, banDb_w, nil, replayBlocker{}, resultCh, nil)
+	if err := j.Reload(cfg, rules); err != nil {
+		return err
+	}
+
+	files = sortReplayFiles(p, files)
+
+	eventCh := make(chan parser.Event, 100)
+	entryCh := make(chan *storage.LogEntry, 100)
+	go func() {
+		p.Parse(eventCh, entryCh)
+		close(entryCh)
+	}()
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(eventCh)
+		for _, name := range files {
+			n, err := replayFile(name, eventCh)
+			stats.lines += n
+			if err != nil {
+				readErr <- err
+				return
+			}
+		}
+		readErr <- nil
+	}()
+
+	var swept, latest time.Time
+	for entry := range entryCh {
+		stats.entries++
+		if !entry.Time.IsZero() {
+			if entry.Time.Before(latest.Add(-replayMaxSkew)) {
+				stats.backwards++
+			}
+			if entry.Time.After(latest) {
+				latest = entry.Time
+			}
+		}
+		now = entry.When().Local()
+		// Lift bans that expired by the time of this line, at most once a second.
+		if second := now.Truncate(time.Second); second.After(swept) {
+			if _, err := banDb_w.RemoveExpiredBans(); err != nil {
+				return err
+			}
+			swept = second
+		}
+		j.Hear(entry)
+	}
+	close(resultCh)
+	<-hitsDone
+	if err := <-readErr; err != nil {
+		return err
+	}
+
+	events, err := banDb_r.History(storage.HistoryFilter{})
+	if err != nil {
+		return err
+	}
+	printReplayReport(stats, events)
+	return nil
+}
+
+// sortReplayFiles orders files by the log time of their first line with a
+// timestamp, so rotated logs given newest first, as access.log* expands,
+// are replayed oldest first. If stdin or a file without a timestamp is
+// among them, the order is left as given.
+func sortReplayFiles(p parser.Parser, files []string) []string {
+	firstTimes := make(map[string]time.Time, len(files))
+	for _, name := range files {
+		if name == "-" {
+			return files
+		}
+		first, err := firstLogTime(p, name)
+		if err != nil || first.IsZero() {
+			return files
+		}
+		firstTimes[name] = first
+	}
+	sorted := slices.Clone(files)
+	slices.SortStableFunc(sorted, func(a, b string) int {
+		return firstTimes[a].Compare(firstTimes[b])
+	})
+	return sorted
+}
+
+// firstLogTime returns the time of the first line of the named file that p
+// parses with a timestamp, looking at replaySniffLines lines at most. It
+// returns the zero time if there is none.
+func firstLogTime(p parser.Parser, name string) (time.Time, error) {
+	scanner, closeFile, err := openReplayFile(name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer closeFile()
+	for lines := 0; lines < replaySniffLines && scanner.Scan(); lines++ {
+		if entry := parseOne(p, scanner.Text()); entry != nil && !entry.Time.IsZero() {
+			return entry.Time, nil
+		}
+	}
+	return time.Time{}, scanner.Err()
+}
+
+// openReplayFile returns a line scanner over the named file, or stdin for
+// "-", and a function that closes it. Gzip files are recognized by their
+// magic number.
+func openReplayFile(name string) (*bufio.Scanner, func(), error) {
+	var r io.Reader = os.Stdin
+	var closers []io.Closer
+	closeAll := func() {
+		for _, c := range slices.Backward(closers) {
+			_ = c.Close()
+		}
+	}
+	if name != "-" {
+		// #nosec G304 - the user asked for this file
+		file, err := os.Open(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		closers = append(closers, file)
+		r = file
+	}
+
+	buffered := bufio.NewReader(r)
+	r = buffered
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("%s: %w", name, err)
+		}
+		closers = append(closers, gz)
+		r = gz
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), replayMaxLine)
+	return scanner, closeAll, nil
+}
+
+// replayFile sends every line of the named file to eventCh and returns how
+// many there were.
+func replayFile(name string, eventCh chan<- parser.Event) (int, error) {
+	scanner, closeFile, err := openReplayFile(name)
+	if err != nil {
+		return 0, err
+	}
+	defer closeFile()
+
+	lines := 0
+	for scanner.Scan() {
+		eventCh <- parser.Event{Data: scanner.Text()}
+		lines++
+	}
+	if err := scanner.Err(); err != nil {
+		return lines, fmt.Errorf("%s: %w", name, err)
+	}
+	return lines, nil
+}
+
+func printReplayReport(stats replayStats, events []storage.BanEvent) {
+	fmt.Printf("Read %d lines, parsed %d entries\n", stats.lines, stats.entries)
+	if stats.backwards > 0 {
+		fmt.Printf("Warning: %d entries are more than %s older than an entry before them; "+
+			"give the files oldest first, or hits may be missed\n", stats.backwards, replayMaxSkew)
+	}
+
+	bansByRule := make(map[string]int)
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.SetStyle(table.StyleBold)
+	t.AppendHeader(table.Row{"№", "IP", "Rule", "Banned At", "Expires At"})
+	bans := 0
+	for _, e := range events {
+		if e.Event != storage.EventBan {
+			continue
+		}
+		bans++
+		bansByRule[e.Rule]++
+		rule := e.Rule
+		if e.Source == storage.SourceMonitor {
+			rule += " (monitor)"
+		}
+		t.AppendRow(table.Row{bans, e.IP, rule, e.CreatedAt, e.Expired})
+	}
+	if bans == 0 {
+		fmt.Println("No IP would have been banned")
+	} else {
+		t.AppendFooter(table.Row{"", "", "", "Bans", bans})
+		t.Render()
+	}
+
+	if len(stats.hits) == 0 {
+		return
+	}
+	names := make([]string, 0, len(stats.hits))
+	for name := range stats.hits {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	rt := table.NewWriter()
+	rt.SetOutputMirror(os.Stdout)
+	rt.AppendHeader(table.Row{"Rule", "Hits", "Bans"})
+	for _, name := range names {
+		rt.AppendRow(table.Row{name, stats.hits[name], bansByRule[name]})
+	}
+	rt.Render()
+}
+
+// replayBlocker stands in for the firewall during a replay; bans only go to
+// the in-memory database.
+type replayBlocker struct{}
+
+func (replayBlocker) Ban(string) error            { return nil }
+func (replayBlocker) Unban(string) error          { return nil }
+func (replayBlocker) Setup(string) error          { return nil }
+func (replayBlocker) PortOpen(int, string) error  { return nil }
+func (replayBlocker) PortClose(int, string) error { return nil }
+
+func ReplayRegister() {
+	ReplayCmd.Flags().StringVarP(&replayService, "service", "s", "", "service whose parser and rules to use")
+}
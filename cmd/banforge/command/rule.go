@@ -18,6 +18,7 @@ var (
 	method   string
 	ttl      string
 	maxRetry int
+	findTime string
 	editName string
 )
 
@@ -46,7 +47,7 @@ var AddCmd = &cobra.Command{
 		if ttl == "" {
 			ttl = "1y"
 		}
-		err := config.NewRule(name, service, path, status, method, ttl, maxRetry)
+		err := config.NewRule(name, service, path, status, method, ttl, maxRetry, findTime)
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
@@ -84,7 +85,7 @@ var RemoveCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		ruleName := args[0]
 		fileName := config.SanitizeRuleFilename(ruleName) + ".toml"
-		filePath := filepath.Join("/etc/banforge/rules.d", fileName)
+		filePath := filepath.Join(config.RulesDir, fileName)
 
 		if _, err := os.Stat(filePath); os.IsNotExist(err) {
 			fmt.Printf("Rule '%s' not found\n", ruleName)
@@ -117,17 +118,19 @@ var ListCmd = &cobra.Command{
 		t := table.NewWriter()
 		t.SetOutputMirror(os.Stdout)
 		t.AppendHeader(table.Row{
-			"Name", "Service", "Path", "Status", "Method", "MaxRetry", "BanTime",
+			"Name", "Service", "Priority", "Path", "Status", "Method", "MaxRetry", "FindTime", "BanTime",
 		})
 
 		for _, rule := range rules {
 			t.AppendRow(table.Row{
 				rule.Name,
 				rule.ServiceName,
+				rule.Priority,
 				rule.Path,
 				rule.Status,
 				rule.Method,
 				rule.MaxRetry,
+				rule.FindTime,
 				rule.BanTime,
 			})
 		}
@@ -140,18 +143,28 @@ func RuleRegister() {
 	RuleCmd.AddCommand(EditCmd)
 	RuleCmd.AddCommand(RemoveCmd)
 	RuleCmd.AddCommand(ListCmd)
+	RuleCmd.AddCommand(RuleTestCmd)
 
 	AddCmd.Flags().StringVarP(&name, "name", "n", "", "rule name (required)")
 	AddCmd.Flags().StringVarP(&service, "service", "s", "", "service name (required)")
 	AddCmd.Flags().StringVarP(&path, "path", "p", "", "request path")
-	AddCmd.Flags().StringVarP(&status, "status", "c", "", "status code")
-	AddCmd.Flags().StringVarP(&method, "method", "m", "", "HTTP method")
+	AddCmd.Flags().StringVarP(&status, "status", "c", "", "status code, class or range, comma separated (e.g., 4xx,500-599)")
+	AddCmd.Flags().StringVarP(&method, "method", "m", "", "HTTP methods, comma separated")
 	AddCmd.Flags().StringVarP(&ttl, "ttl", "t", "", "ban time (e.g., 1h, 1d, 1y)")
 	AddCmd.Flags().IntVarP(&maxRetry, "max_retry", "r", 0, "max retry before ban")
+	AddCmd.Flags().StringVarP(
+		&findTime,
+		"find_time",
+		"f",
+		"",
+		"window in which max_retry is counted (default 10m)",
+	)
 
 	EditCmd.Flags().StringVarP(&editName, "name", "n", "", "rule name to edit (required)")
 	EditCmd.Flags().StringVarP(&service, "service", "s", "", "new service name")
 	EditCmd.Flags().StringVarP(&path, "path", "p", "", "new path")
-	EditCmd.Flags().StringVarP(&status, "status", "c", "", "new status code")
-	EditCmd.Flags().StringVarP(&method, "method", "m", "", "new HTTP method")
+	EditCmd.Flags().StringVarP(&status, "status", "c", "", "new status codes, comma separated")
+	EditCmd.Flags().StringVarP(&method, "method", "m", "", "new HTTP methods, comma separated")
+
+	RuleTestCmd.Flags().StringVarP(&testService, "service", "s", "", "service whose parser and rules to use (required)")
 }
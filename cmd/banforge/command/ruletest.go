@@ -0,0 +1,200 @@
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/d3m0k1d/BanForge/internal/config"
+	"github.com/d3m0k1d/BanForge/internal/judge"
+	"github.com/d3m0k1d/BanForge/internal/logger"
+	"github.com/d3m0k1d/BanForge/internal/parser"
+	"github.com/d3m0k1d/BanForge/internal/storage"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+var testService string
+
+var RuleTestCmd = &cobra.Command{
+	Use:   "test -s <service> [line]",
+	Short: "Check a log line against the rules",
+	Long: "Parse a log line with the parser of a service and show the parsed fields, " +
+		"every rule of the service that matches it and why the others do not. " +
+		"Without a line argument, each line read from stdin is checked.",
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		logger.Quiet()
+		ok, err := runRuleTest(testService, args)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+	},
+}
+
+// runRuleTest reports on each line and returns false if any of them did
+// not parse.
+func runRuleTest(serviceName string, args []string) (bool, error) {
+	if serviceName == "" {
+		return false, fmt.Errorf("service name can't be empty (use -s flag)")
+	}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return false, err
+	}
+	rules, err := config.LoadRuleConfig()
+	if err != nil {
+		return false, err
+	}
+	svc := lookupService(cfg, serviceName)
+	p, err := parserFor(svc)
+	if err != nil {
+		return false, fmt.Errorf("service %s: %w", serviceName, err)
+	}
+	j := judge.New(nil, nil, nil, nil, nil, nil)
+	if err := j.Reload(cfg, rules); err != nil {
+		return false, err
+	}
+
+	if len(args) == 1 {
+		return testLine(j, p, svc, args[0], false), nil
+	}
+	allParsed := true
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 64*1024), replayMaxLine)
+	for scanner.Scan() {
+		if scanner.Text() == "" {
+			continue
+		}
+		if !testLine(j, p, svc, scanner.Text(), true) {
+			allParsed = false
+		}
+	}
+	return allParsed, scanner.Err()
+}
+
+func testLine(j *judge.Judge, p parser.Parser, svc config.Service, line string, echo bool) bool {
+	if echo {
+		fmt.Println(line)
+	}
+	entry := parseOne(p, line)
+	if entry == nil {
+		fmt.Printf("Not recognized by the %s parser\n\n", svc.ParserName())
+		return false
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Field", "Value"})
+	t.AppendRows([]table.Row{
+		{"service", entry.Service},
+		{"ip", entry.IP},
+		{"path", entry.Path},
+		{"method", entry.Method},
+		{"status", entry.Status},
+		{"user_agent", entry.UserAgent},
+	})
+	if !entry.Time.IsZero() {
+		t.AppendRow(table.Row{"time", entry.Time.Format(time.RFC3339)})
+	}
+	t.Render()
+
+	verdicts := j.Explain(entry)
+	if len(verdicts) == 0 {
+		fmt.Printf("No rules for service %s\n\n", entry.Service)
+		return true
+	}
+	rt := table.NewWriter()
+	rt.SetOutputMirror(os.Stdout)
+	rt.AppendHeader(table.Row{"Rule", "Result", "Details"})
+	for _, v := range verdicts {
+		rt.AppendRow(table.Row{v.Rule.Name, verdictResult(v), verdictDetails(v, svc, entry)})
+	}
+	rt.Render()
+	fmt.Println()
+	return true
+}
+
+// parseOne runs line through p and returns the entry it produces, or nil
+// if p skips the line.
+func parseOne(p parser.Parser, line string) *storage.LogEntry {
+	eventCh := make(chan parser.Event, 1)
+	resultCh := make(chan *storage.LogEntry, 1)
+	eventCh <- parser.Event{Data: line}
+	close(eventCh)
+	p.Parse(eventCh, resultCh)
+	close(resultCh)
+	return <-resultCh
+}
+
+func verdictResult(v judge.RuleVerdict) string {
+	switch {
+	case len(v.Mismatches) > 0:
+		return "no match"
+	case v.Shadowed:
+		return "match, not evaluated"
+	case v.Ignored:
+		return "match, IP ignored"
+	default:
+		return "match"
+	}
+}
+
+func verdictDetails(v judge.RuleVerdict, svc config.Service, entry *storage.LogEntry) string {
+	if len(v.Mismatches) > 0 {
+		reasons := make([]string, len(v.Mismatches))
+		for i, field := range v.Mismatches {
+			reasons[i] = mismatchReason(v.Rule, svc, entry, field)
+		}
+		return strings.Join(reasons, "\n")
+	}
+	if v.Shadowed {
+		return `an earlier rule matched and rule_match is "first"`
+	}
+	if v.Ignored {
+		return "the IP is in ignore_ip and is never banned"
+	}
+	findTime, err := v.Rule.FindTimeDuration()
+	if err != nil {
+		return err.Error()
+	}
+	details := "bans on the first hit"
+	if v.Rule.MaxRetry > 0 {
+		window := v.Rule.FindTime
+		if window == "" {
+			window = findTime.String()
+		}
+		details = fmt.Sprintf("bans after %d hits within %s", v.Rule.MaxRetry, window)
+	}
+	if v.Monitor {
+		details += " (monitor mode, not at the firewall)"
+	}
+	return details
+}
+
+func mismatchReason(rule config.Rule, svc config.Service, entry *storage.LogEntry, field string) string {
+	switch field {
+	case judge.FieldMethod:
+		return fmt.Sprintf("method %q is not one of %s", entry.Method, strings.Join(rule.Method, ", "))
+	case judge.FieldStatus:
+		if len(rule.Status) == 0 {
+			return fmt.Sprintf("status %q is not one of %s, the default for %s rules without status",
+				entry.Status, strings.Join(svc.DefaultStatus(), ", "), svc.Name)
+		}
+		return fmt.Sprintf("status %q is not one of %s", entry.Status, strings.Join(rule.Status, ", "))
+	case judge.FieldPath:
+		return fmt.Sprintf("path %q does not match %q", entry.Path, rule.Path)
+	case judge.FieldPathRegex:
+		return fmt.Sprintf("path %q does not match path_regex %q", entry.Path, rule.PathRegex)
+	case judge.FieldUserAgentRegex:
+		return fmt.Sprintf("user agent %q does not match user_agent_regex %q", entry.UserAgent, rule.UserAgentRegex)
+	default:
+		return field + " does not match"
+	}
+}
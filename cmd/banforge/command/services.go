@@ -0,0 +1,182 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/d3m0k1d/BanForge/internal/config"
+	"github.com/d3m0k1d/BanForge/internal/logger"
+	"github.com/d3m0k1d/BanForge/internal/parser"
+	"github.com/d3m0k1d/BanForge/internal/storage"
+)
+
+// serviceKey identifies a running scanner. A service whose log source
+// changes on reload is stopped and started again under its new key.
+type serviceKey struct {
+	name    string
+	logging string
+	logPath string
+	journal string
+	parser  string
+	pattern string
+}
+
+func keyOf(svc config.Service) serviceKey {
+	return serviceKey{
+		name:    svc.Name,
+		logging: svc.Logging,
+		logPath: svc.LogPath,
+		journal: strings.Join(svc.SyslogIdentifier, ",") + " " + strings.Join(svc.JournalMatch, " "),
+		parser:  svc.ParserName(),
+		pattern: svc.Pattern,
+	}
+}
+
+// serviceManager runs one scanner and parser per enabled [[service]] and
+// keeps them in line with the config when it is reloaded.
+type serviceManager struct {
+	log       *logger.Logger
+	entryCh   chan<- *storage.LogEntry
+	positions parser.Positions
+	wg        sync.WaitGroup
+	running   map[serviceKey]*parser.Scanner
+}
+
+func newServiceManager(
+	log *logger.Logger,
+	entryCh chan<- *storage.LogEntry,
+	positions parser.Positions,
+) *serviceManager {
+	return &serviceManager{
+		log:       log,
+		entryCh:   entryCh,
+		positions: positions,
+		running:   make(map[serviceKey]*parser.Scanner),
+	}
+}
+
+// Sync stops scanners for services that were removed or disabled and starts
+// scanners for services that were added or enabled. Services that did not
+// change keep running.
+func (m *serviceManager) Sync(services []config.Service) {
+	wanted := make(map[serviceKey]bool)
+	for _, svc := range services {
+		if svc.Enabled {
+			wanted[keyOf(svc)] = true
+		}
+	}
+
+	for key, s := range m.running {
+		if wanted[key] {
+			continue
+		}
+		m.log.Info("Stopping service", "name", key.name, "logging", key.logging, "path", key.logPath)
+		s.Stop()
+		delete(m.running, key)
+	}
+
+	for _, svc := range services {
+		m.log.Info(
+			"Processing service",
+			"name", svc.Name,
+			"enabled", svc.Enabled,
+			"path", svc.LogPath,
+		)
+		if !svc.Enabled {
+			m.log.Info("Service disabled, skipping", "name", svc.Name)
+			continue
+		}
+		if _, ok := m.running[keyOf(svc)]; ok {
+			continue
+		}
+		if err := m.start(svc); err != nil {
+			m.log.Error("Failed to start service", "service", svc.Name, "error", err)
+		}
+	}
+}
+
+func (m *serviceManager) start(svc config.Service) error {
+	p, err := parserFor(svc)
+	if err != nil {
+		return err
+	}
+
+	var s *parser.Scanner
+	switch svc.Logging {
+	case "file":
+		m.log.Info("Logging to file", "path", svc.LogPath)
+		s, err = parser.NewScannerTail(svc.LogPath, m.positions)
+	case "journald":
+		m.log.Info(
+			"Logging to journald",
+			"unit", svc.LogPath,
+			"identifier", svc.SyslogIdentifier.String(),
+			"match", strings.Join(svc.JournalMatch, " "),
+		)
+		s, err = parser.NewScannerJournald(parser.JournalFilter{
+			Unit:        svc.LogPath,
+			Identifiers: svc.SyslogIdentifier,
+			Matches:     svc.JournalMatch,
+		}, m.positions)
+	default:
+		return fmt.Errorf("invalid logging type %q", svc.Logging)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create scanner: %w", err)
+	}
+
+	m.running[keyOf(svc)] = s
+	go s.Start()
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.log.Info("Starting parser", "service", svc.Name)
+		p.Parse(s.Events(), m.entryCh)
+	}()
+	return nil
+}
+
+// StopAll stops every scanner and waits for their parsers to drain.
+func (m *serviceManager) StopAll() {
+	for key, s := range m.running {
+		s.Stop()
+		delete(m.running, key)
+	}
+	m.wg.Wait()
+}
+
+// parserFor returns the parser for svc: its own pattern if it has one,
+// otherwise the registered parser it names.
+func parserFor(svc config.Service) (parser.Parser, error) {
+	if svc.Pattern != "" {
+		return parser.NewRegexParser(svc.Name, svc.Pattern)
+	}
+	return parser.New(svc.ParserName(), svc.Name)
+}
+
+// lookupService returns the configured service called name. A name that is
+// not configured is taken as the name of a built-in parser, so the offline
+// commands work for services that are not set up yet.
+func lookupService(cfg *config.Config, name string) config.Service {
+	for _, svc := range cfg.Service {
+		if svc.Name == name {
+			return svc
+		}
+	}
+	return config.Service{Name: name}
+}
+
+// checkParsers reports the first enabled service without a usable parser.
+func checkParsers(services []config.Service) error {
+	for _, svc := range services {
+		if !svc.Enabled {
+			continue
+		}
+		if _, err := parserFor(svc); err != nil {
+			return fmt.Errorf("service %s: %w", svc.Name, err)
+		}
+	}
+	return nil
+}
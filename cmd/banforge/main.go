@@ -29,8 +29,13 @@ func Execute() {
 	rootCmd.AddCommand(command.BanListCmd)
 	rootCmd.AddCommand(command.VersionCmd)
 	rootCmd.AddCommand(command.PortCmd)
+	rootCmd.AddCommand(command.HistoryCmd)
+	rootCmd.AddCommand(command.ReplayCmd)
+	command.DaemonRegister()
 	command.RuleRegister()
 	command.FwRegister()
+	command.HistoryRegister()
+	command.ReplayRegister()
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -1,11 +1,15 @@
 package blocker
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/d3m0k1d/BanForge/internal/logger"
 )
 
+// BlockerEngine is implemented by each firewall backend. Ban and Unban accept
+// a single address or a CIDR prefix.
 type BlockerEngine interface {
 	Ban(ip string) error
 	Unban(ip string) error
@@ -14,6 +18,34 @@ type BlockerEngine interface {
 	PortClose(port int, protocol string) error
 }
 
+// ErrCovered is returned by Unban when the target is lifted but stays
+// banned as part of a wider prefix ban.
+var ErrCovered = errors.New("still banned as part of")
+
+// TimedBan is a ban that the firewall lifts by itself after Duration. A zero
+// Duration never expires.
+type TimedBan struct {
+	Target   string
+	Duration time.Duration
+}
+
+// Expiring is implemented by backends whose bans expire in the firewall
+// itself, so they are lifted on time even while the daemon is not running.
+// BanFor applies many bans at once. Re-applying a ban that is in place is
+// not an error, so after a failure the bans can be retried one by one.
+type Expiring interface {
+	BanFor(bans ...TimedBan) error
+}
+
+// BanFor bans target through b for duration. Backends that do not implement
+// Expiring ban it until Unban is called.
+func BanFor(b BlockerEngine, target string, duration time.Duration) error {
+	if e, ok := b.(Expiring); ok {
+		return e.BanFor(TimedBan{Target: target, Duration: duration})
+	}
+	return b.Ban(target)
+}
+
 func GetBlocker(fw string, config string) (BlockerEngine, error) {
 	switch fw {
 	case "ufw":
@@ -2,19 +2,33 @@ package blocker
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
-	"net"
+	"net/netip"
 	"os"
 	"os/exec"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/d3m0k1d/BanForge/internal/logger"
 	"github.com/d3m0k1d/BanForge/internal/metrics"
+	"github.com/google/nftables"
+	"golang.org/x/sys/unix"
 )
 
+// Nftables bans through the sets of the inet banforge table. Bans and
+// unbans talk to the kernel over netlink; Setup and the port commands still
+// use the nft tool.
 type Nftables struct {
 	logger *logger.Logger
 	config string
+	// mu keeps the batches of concurrent callers apart: conn queues
+	// messages until Flush.
+	mu   sync.Mutex
+	conn *nftables.Conn
 }
 
 func NewNftables(logger *logger.Logger, config string) *Nftables {
@@ -24,110 +38,367 @@ func NewNftables(logger *logger.Logger, config string) *Nftables {
 	}
 }
 
-func (n *Nftables) Ban(ip string) error {
-	set, address, err := nftablesSetForIP(ip)
-	if err != nil {
-		return err
-	}
-	metrics.IncBanAttempt("nftables")
+// banforgeTable is the table Setup loads from banforge.nft.
+var banforgeTable = &nftables.Table{Name: "banforge", Family: nftables.TableFamilyINet}
 
-	exists, err := nftablesElementExists(set, address)
-	if err != nil {
-		metrics.IncError()
-		return err
+// Sets of banforge.nft. Addresses and prefixes are kept apart: the kernel
+// refuses overlapping ranges in one set, and a subnet ban must not swallow
+// the bans of addresses inside it, which outlive it.
+var nftablesSets = []string{"blocked_ipv4", "blocked_ipv6", "blocked_ipv4_net", "blocked_ipv6_net"}
+
+// nftablesSet describes one of the sets of banforge.nft. The kernel finds
+// sets by table and name, so no lookup is needed.
+func nftablesSet(name string) *nftables.Set {
+	keyType := nftables.TypeIPAddr
+	if strings.HasPrefix(name, "blocked_ipv6") {
+		keyType = nftables.TypeIP6Addr
 	}
-	if exists {
-		n.logger.Info("IP already banned", "ip", address, "set", set)
-		return nil
+	return &nftables.Set{
+		Table:      banforgeTable,
+		Name:       name,
+		KeyType:    keyType,
+		Interval:   true,
+		HasTimeout: true,
 	}
+}
 
-	// #nosec G204 - address is parsed and normalized by net.ParseIP
-	cmd := exec.Command(
-		"nft", "add", "element", "inet", "banforge", set, "{", address, "}",
-	)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		n.logger.Error("failed to ban IP",
-			"ip", address,
-			"set", set,
-			"error", err.Error(),
-			"output", string(output))
-		metrics.IncError()
-		return fmt.Errorf("failed to add IP to nftables set: %w", err)
+func (n *Nftables) Ban(ip string) error {
+	return n.BanFor(TimedBan{Target: ip})
+}
+
+// nftablesBatchSize limits the bans sent in one netlink transaction, which
+// must fit into a single netlink message.
+const nftablesBatchSize = 1000
+
+// BanFor adds the bans to their sets, up to nftablesBatchSize of them per
+// netlink transaction. The duration of each ban becomes the timeout of its
+// element, so the kernel drops it on time.
+func (n *Nftables) BanFor(bans ...TimedBan) error {
+	type element struct {
+		set     string
+		target  string
+		timeout time.Duration
+	}
+	elements := make([]element, 0, len(bans))
+	for _, ban := range bans {
+		set, target, err := nftablesSetForIP(ban.Target)
+		if err != nil {
+			return err
+		}
+		elements = append(elements, element{set: set, target: target, timeout: nftablesTimeout(ban.Duration)})
+		metrics.IncBanAttempt("nftables")
 	}
 
-	n.logger.Info("IP banned", "ip", address, "set", set)
-	metrics.IncBan("nftables")
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for batch := range slices.Chunk(elements, nftablesBatchSize) {
+		err := n.flush(func(c *nftables.Conn) error {
+			bySet := make(map[string][]nftables.SetElement)
+			for _, e := range batch {
+				bySet[e.set] = append(bySet[e.set], nftablesElements(e.target, e.timeout)...)
+			}
+			for set, setElements := range bySet {
+				if err := c.SetAddElements(nftablesSet(set), setElements); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		covered := false
+		if err != nil && len(elements) == 1 && errors.Is(err, unix.EEXIST) {
+			covered, err = n.banOverlapping(elements[0].set, elements[0].target, elements[0].timeout)
+		}
+		if err != nil {
+			n.logger.Error("failed to ban IP",
+				"bans", len(batch),
+				"error", err.Error())
+			metrics.IncError()
+			return fmt.Errorf("failed to add IP to nftables set: %w", err)
+		}
+
+		if covered {
+			n.logger.Info("IP already banned", "ip", elements[0].target, "set", elements[0].set)
+			return nil
+		}
+		for _, e := range batch {
+			n.logger.Info("IP banned", "ip", e.target, "set", e.set, "timeout", e.timeout)
+			metrics.IncBan("nftables")
+		}
+	}
 	return nil
 }
 
-func (n *Nftables) Unban(ip string) error {
-	set, address, err := nftablesSetForIP(ip)
+// banOverlapping adds target to a set that holds ranges overlapping it,
+// which the kernel refuses. It reports true if a range already covers
+// target, as a ban that is in place does. Only prefix bans can overlap
+// otherwise: narrower prefixes inside target are replaced by it, and it
+// keeps the longest of their timeouts so none of them is lifted early.
+func (n *Nftables) banOverlapping(set string, target string, timeout time.Duration) (bool, error) {
+	s := nftablesSet(set)
+	existing, err := n.conn.GetSetElements(s)
 	if err != nil {
-		return err
+		return false, err
 	}
-	metrics.IncUnbanAttempt("nftables")
+	start, end := nftablesRange(target)
+	var inside []nftables.SetElement
+	replaced := 0
+	for _, iv := range nftablesIntervals(existing) {
+		if iv.covers(start, end) {
+			return true, nil
+		}
+		if (nftablesInterval{start: start, end: end}).covers(iv.start, iv.end) {
+			inside = append(inside, iv.elements()...)
+			timeout = longerTimeout(timeout, iv.expires)
+			replaced++
+		}
+	}
+	n.logger.Info("Replacing bans inside range", "ip", target, "set", set, "replaced", replaced, "timeout", timeout)
+	return false, n.flush(func(c *nftables.Conn) error {
+		if len(inside) > 0 {
+			if err := c.SetDeleteElements(s, inside); err != nil {
+				return err
+			}
+		}
+		return c.SetAddElements(s, nftablesElements(target, timeout))
+	})
+}
 
-	exists, err := nftablesElementExists(set, address)
+// Unban removes target from its set. If a wider prefix ban still covers
+// target afterwards, it returns an error wrapping ErrCovered.
+func (n *Nftables) Unban(ip string) error {
+	set, target, err := nftablesSetForIP(ip)
 	if err != nil {
-		metrics.IncError()
 		return err
 	}
-	if !exists {
-		n.logger.Info("IP already unbanned", "ip", address, "set", set)
-		return nil
-	}
+	metrics.IncUnbanAttempt("nftables")
 
-	// #nosec G204 - address is parsed and normalized by net.ParseIP
-	cmd := exec.Command(
-		"nft", "delete", "element", "inet", "banforge", set, "{", address, "}",
-	)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	err = n.flush(func(c *nftables.Conn) error {
+		return c.SetDeleteElements(nftablesSet(set), nftablesElements(target, 0))
+	})
+	switch {
+	case errors.Is(err, unix.ENOENT):
+		// The ban has already expired in the set, or target is a prefix
+		// that was merged into a wider one.
+		n.logger.Debug("IP already unbanned", "ip", target, "set", set)
+	case err != nil:
 		n.logger.Error("failed to unban IP",
-			"ip", address,
+			"ip", target,
 			"set", set,
-			"error", err.Error(),
-			"output", string(output))
+			"error", err.Error())
 		metrics.IncError()
 		return fmt.Errorf("failed to delete IP from nftables set: %w", err)
+	default:
+		n.logger.Info("IP unbanned", "ip", target, "set", set)
+		metrics.IncUnban("nftables")
 	}
 
-	n.logger.Info("IP unbanned", "ip", address, "set", set)
-	metrics.IncUnban("nftables")
+	netSet := nftablesNetSet(set)
+	existing, err := n.conn.GetSetElements(nftablesSet(netSet))
+	if err != nil {
+		metrics.IncError()
+		return fmt.Errorf("failed to inspect nftables set %s: %w", netSet, err)
+	}
+	start, end := nftablesRange(target)
+	for _, iv := range nftablesIntervals(existing) {
+		if iv.covers(start, end) {
+			return fmt.Errorf("%s: %w %s", target, ErrCovered, iv)
+		}
+	}
 	return nil
 }
 
+// flush sends the messages queued by build to the kernel as one
+// transaction. n.mu must be held.
+func (n *Nftables) flush(build func(c *nftables.Conn) error) error {
+	if n.conn == nil {
+		conn, err := nftables.New()
+		if err != nil {
+			return fmt.Errorf("failed to open netlink connection: %w", err)
+		}
+		n.conn = conn
+	}
+	if err := build(n.conn); err != nil {
+		// Drop whatever build queued before it failed.
+		n.conn = nil
+		return err
+	}
+	return n.conn.Flush()
+}
+
 func nftablesSetForIP(ip string) (string, string, error) {
-	parsed := net.ParseIP(ip)
-	if parsed == nil {
-		return "", "", fmt.Errorf("invalid IP: %s", ip)
+	target, err := NormalizeTarget(ip)
+	if err != nil {
+		return "", "", err
 	}
 
-	if ipv4 := parsed.To4(); ipv4 != nil {
-		return "blocked_ipv4", ipv4.String(), nil
+	if IsPrefix(target) {
+		prefix := netip.MustParsePrefix(target)
+		if prefix.Addr().Is4() {
+			return "blocked_ipv4_net", target, nil
+		}
+		return "blocked_ipv6_net", target, nil
 	}
 
-	return "blocked_ipv6", parsed.String(), nil
+	addr := netip.MustParseAddr(target)
+	if addr.Is4() || addr.Is4In6() {
+		return "blocked_ipv4", addr.Unmap().String(), nil
+	}
+	return "blocked_ipv6", addr.String(), nil
 }
 
-func nftablesElementExists(set string, ip string) (bool, error) {
-	// #nosec G204 - set is selected internally and ip is normalized by net.ParseIP
-	cmd := exec.Command(
-		"nft", "get", "element", "inet", "banforge", set, "{", ip, "}",
-	)
-	if err := cmd.Run(); err == nil {
-		return true, nil
+// nftablesNetSet returns the prefix set of the address family of set.
+func nftablesNetSet(set string) string {
+	return strings.TrimSuffix(set, "_net") + "_net"
+}
+
+// nftablesTimeout turns a ban duration into an element timeout. The kernel
+// counts in milliseconds and reads zero as no timeout, so a ban about to
+// expire still gets a second.
+func nftablesTimeout(d time.Duration) time.Duration {
+	if d == 0 {
+		return 0
 	}
+	return max(d, time.Second)
+}
 
-	// #nosec G204 - set is selected internally by nftablesSetForIP
-	cmd = exec.Command("nft", "list", "set", "inet", "banforge", set)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return false, fmt.Errorf("failed to inspect nftables set %s: %w: %s", set, err, output)
+// longerTimeout returns the later of two timeouts, where zero never expires.
+func longerTimeout(a, b time.Duration) time.Duration {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return max(a, b)
+}
+
+// nftablesRange returns the first address of a target from
+// nftablesSetForIP and the address after its last one. end is invalid when
+// the target runs to the last address of its family.
+func nftablesRange(target string) (start, end netip.Addr) {
+	if !IsPrefix(target) {
+		start = netip.MustParseAddr(target)
+		return start, start.Next()
+	}
+	prefix := netip.MustParsePrefix(target)
+	last := prefix.Addr().AsSlice()
+	for bit := prefix.Bits(); bit < len(last)*8; bit++ {
+		last[bit/8] |= 0x80 >> (bit % 8)
+	}
+	lastAddr, _ := netip.AddrFromSlice(last)
+	return prefix.Addr(), lastAddr.Next()
+}
+
+// nftablesElements encodes target as a range of an interval set: an element
+// for its first address, which carries the timeout, and one flagged as the
+// interval end for the address after its last.
+func nftablesElements(target string, timeout time.Duration) []nftables.SetElement {
+	start, end := nftablesRange(target)
+	elements := []nftables.SetElement{{Key: start.AsSlice(), Timeout: timeout}}
+	if end.IsValid() {
+		elements = append(elements, nftables.SetElement{Key: end.AsSlice(), IntervalEnd: true})
+	}
+	return elements
+}
+
+// nftablesInterval is a range [start, end) of an interval set. An invalid
+// end runs to the last address.
+type nftablesInterval struct {
+	start   netip.Addr
+	end     netip.Addr
+	expires time.Duration // time left, zero if it never expires
+}
+
+// nftablesIntervals pairs the start and end elements of a set dump. The
+// kernel leaves the end element of an expired range behind for a while;
+// those are skipped.
+func nftablesIntervals(elements []nftables.SetElement) []nftablesInterval {
+	type point struct {
+		addr    netip.Addr
+		end     bool
+		expires time.Duration
+	}
+	points := make([]point, 0, len(elements))
+	for _, e := range elements {
+		if addr, ok := netip.AddrFromSlice(e.Key); ok {
+			points = append(points, point{addr: addr, end: e.IntervalEnd, expires: e.Expires})
+		}
 	}
+	slices.SortFunc(points, func(a, b point) int {
+		if c := a.addr.Compare(b.addr); c != 0 {
+			return c
+		}
+		// A range ending where the next one starts is closed first.
+		switch {
+		case a.end && !b.end:
+			return -1
+		case !a.end && b.end:
+			return 1
+		}
+		return 0
+	})
+
+	var intervals []nftablesInterval
+	var open nftablesInterval
+	for _, p := range points {
+		switch {
+		case !p.end:
+			if open.start.IsValid() {
+				intervals = append(intervals, open)
+			}
+			open = nftablesInterval{start: p.addr, expires: p.expires}
+		case open.start.IsValid():
+			open.end = p.addr
+			intervals = append(intervals, open)
+			open = nftablesInterval{}
+		}
+	}
+	if open.start.IsValid() {
+		intervals = append(intervals, open)
+	}
+	return intervals
+}
+
+// covers reports whether iv includes every address of [start, end).
+func (iv nftablesInterval) covers(start, end netip.Addr) bool {
+	if start.Less(iv.start) {
+		return false
+	}
+	if !iv.end.IsValid() {
+		return true
+	}
+	return end.IsValid() && end.Compare(iv.end) <= 0
+}
+
+// elements returns the set elements that make up iv, for deleting it.
+func (iv nftablesInterval) elements() []nftables.SetElement {
+	elements := []nftables.SetElement{{Key: iv.start.AsSlice()}}
+	if iv.end.IsValid() {
+		elements = append(elements, nftables.SetElement{Key: iv.end.AsSlice(), IntervalEnd: true})
+	}
+	return elements
+}
 
-	return false, nil
+func (iv nftablesInterval) String() string {
+	if !iv.end.IsValid() {
+		return iv.start.String() + "-"
+	}
+	return iv.start.String() + "-" + iv.end.Prev().String()
+}
+
+// nftablesSetsUsable reports whether every set of banforge.nft exists with
+// the interval and timeout flags that CIDR bans and BanFor need.
+func nftablesSetsUsable() bool {
+	conn, err := nftables.New()
+	if err != nil {
+		return false
+	}
+	for _, name := range nftablesSets {
+		s, err := conn.GetSetByName(banforgeTable, name)
+		if err != nil || !s.Interval || !s.HasTimeout {
+			return false
+		}
+	}
+	return true
 }
 
 func (n *Nftables) Setup(config string) error {
@@ -140,12 +411,26 @@ func (n *Nftables) Setup(config string) error {
 table inet banforge {
 	set blocked_ipv4 {
 		type ipv4_addr
-		flags timeout
+		flags interval, timeout
+		auto-merge
 	}
 
 	set blocked_ipv6 {
 		type ipv6_addr
-		flags timeout
+		flags interval, timeout
+		auto-merge
+	}
+
+	set blocked_ipv4_net {
+		type ipv4_addr
+		flags interval, timeout
+		auto-merge
+	}
+
+	set blocked_ipv6_net {
+		type ipv6_addr
+		flags interval, timeout
+		auto-merge
 	}
 
 	chain input {
@@ -153,6 +438,8 @@ table inet banforge {
 
 		ip saddr @blocked_ipv4 drop
 		ip6 saddr @blocked_ipv6 drop
+		ip saddr @blocked_ipv4_net drop
+		ip6 saddr @blocked_ipv6_net drop
 	}
 }
 `
@@ -191,13 +478,10 @@ table inet banforge {
 
 	tableExists := exec.Command("nft", "list", "table", "inet", "banforge").Run() == nil
 	if tableExists {
-		ipv4SetExists := exec.Command(
-			"nft", "list", "set", "inet", "banforge", "blocked_ipv4",
-		).Run() == nil
-		ipv6SetExists := exec.Command(
-			"nft", "list", "set", "inet", "banforge", "blocked_ipv6",
-		).Run() == nil
-		if ipv4SetExists && ipv6SetExists {
+		// Tables created by older releases lack the prefix sets, the
+		// interval flag needed for CIDR bans or the timeout flag needed for
+		// expiring bans, so they are replaced as well.
+		if nftablesSetsUsable() {
 			return nil
 		}
 
@@ -0,0 +1,201 @@
+package blocker
+
+import (
+	"errors"
+	"net/netip"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/d3m0k1d/BanForge/internal/logger"
+	"github.com/google/nftables"
+	"golang.org/x/sys/unix"
+)
+
+func TestNftablesRange(t *testing.T) {
+	tests := []struct {
+		target    string
+		wantStart string
+		wantEnd   string // empty: runs to the last address
+	}{
+		{target: "192.0.2.4", wantStart: "192.0.2.4", wantEnd: "192.0.2.5"},
+		{target: "192.0.2.0/24", wantStart: "192.0.2.0", wantEnd: "192.0.3.0"},
+		{target: "2001:db8::/64", wantStart: "2001:db8::", wantEnd: "2001:db8:0:1::"},
+		{target: "255.255.255.255", wantStart: "255.255.255.255"},
+		{target: "128.0.0.0/1", wantStart: "128.0.0.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			start, end := nftablesRange(tt.target)
+			if start.String() != tt.wantStart {
+				t.Errorf("start = %s, want %s", start, tt.wantStart)
+			}
+			if tt.wantEnd == "" {
+				if end.IsValid() {
+					t.Errorf("end = %s, want none", end)
+				}
+			} else if end.String() != tt.wantEnd {
+				t.Errorf("end = %s, want %s", end, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestNftablesElements(t *testing.T) {
+	elements := nftablesElements("192.0.2.4", time.Hour)
+	if len(elements) != 2 {
+		t.Fatalf("nftablesElements() = %+v, want start and end", elements)
+	}
+	start, end := elements[0], elements[1]
+	if start.IntervalEnd || start.Timeout != time.Hour || netip.AddrFrom4([4]byte(start.Key)) != netip.MustParseAddr("192.0.2.4") {
+		t.Errorf("start element = %+v", start)
+	}
+	// The kernel refuses a timeout on an interval end.
+	if !end.IntervalEnd || end.Timeout != 0 || netip.AddrFrom4([4]byte(end.Key)) != netip.MustParseAddr("192.0.2.5") {
+		t.Errorf("end element = %+v", end)
+	}
+}
+
+func TestNftablesTimeout(t *testing.T) {
+	tests := []struct {
+		in, want time.Duration
+	}{
+		{in: 0, want: 0},
+		{in: 500 * time.Millisecond, want: time.Second},
+		{in: -time.Minute, want: time.Second},
+		{in: time.Hour, want: time.Hour},
+	}
+	for _, tt := range tests {
+		if got := nftablesTimeout(tt.in); got != tt.want {
+			t.Errorf("nftablesTimeout(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNftablesIntervals(t *testing.T) {
+	key := func(s string) []byte { return netip.MustParseAddr(s).AsSlice() }
+	// A set dump lists elements in descending order.
+	dump := []nftables.SetElement{
+		{Key: key("255.255.255.255"), Expires: time.Hour},
+		{Key: key("198.51.101.0"), IntervalEnd: true},
+		{Key: key("198.51.100.0")},
+		// Left behind by a range whose start already expired.
+		{Key: key("192.0.2.9"), IntervalEnd: true},
+		{Key: key("192.0.2.6"), IntervalEnd: true},
+		{Key: key("192.0.2.5"), Expires: 2 * time.Hour},
+		{Key: key("192.0.2.5"), IntervalEnd: true},
+		{Key: key("192.0.2.4"), Expires: time.Hour},
+	}
+
+	got := nftablesIntervals(dump)
+	want := []string{
+		"192.0.2.4-192.0.2.4",
+		"192.0.2.5-192.0.2.5",
+		"198.51.100.0-198.51.100.255",
+		"255.255.255.255-",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("nftablesIntervals() = %v, want %v", got, want)
+	}
+	for i, iv := range got {
+		if iv.String() != want[i] {
+			t.Errorf("interval %d = %s, want %s", i, iv, want[i])
+		}
+	}
+	if got[1].expires != 2*time.Hour {
+		t.Errorf("expires = %v, want 2h", got[1].expires)
+	}
+}
+
+func TestNftablesIntervalCovers(t *testing.T) {
+	subnet := nftablesInterval{start: netip.MustParseAddr("192.0.2.0"), end: netip.MustParseAddr("192.0.3.0")}
+	tail := nftablesInterval{start: netip.MustParseAddr("255.255.255.0")}
+
+	tests := []struct {
+		name   string
+		iv     nftablesInterval
+		target string
+		want   bool
+	}{
+		{name: "address inside", iv: subnet, target: "192.0.2.9", want: true},
+		{name: "same range", iv: subnet, target: "192.0.2.0/24", want: true},
+		{name: "smaller range", iv: subnet, target: "192.0.2.128/25", want: true},
+		{name: "larger range", iv: subnet, target: "192.0.0.0/16"},
+		{name: "address outside", iv: subnet, target: "192.0.3.0"},
+		{name: "last address", iv: tail, target: "255.255.255.255", want: true},
+		{name: "before open range", iv: tail, target: "255.255.254.255"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := nftablesRange(tt.target)
+			if got := tt.iv.covers(start, end); got != tt.want {
+				t.Errorf("%s covers %s = %v, want %v", tt.iv, tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+// newNftablesTestTable moves the calling goroutine into a network namespace
+// of its own and creates the banforge table there, as Setup would. The
+// thread is discarded when the test ends. Without CAP_SYS_ADMIN the test is
+// skipped.
+func newNftablesTestTable(t *testing.T) *Nftables {
+	t.Helper()
+	runtime.LockOSThread()
+	if err := unix.Unshare(unix.CLONE_NEWNET); err != nil {
+		t.Skipf("no network namespace: %v", err)
+	}
+	conn, err := nftables.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.AddTable(banforgeTable)
+	for _, name := range nftablesSets {
+		if err := conn.AddSet(nftablesSet(name), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		t.Skipf("no nftables: %v", err)
+	}
+	return NewNftables(logger.New(false), "")
+}
+
+func TestNftablesSubnetUnbanKeepsAddressBans(t *testing.T) {
+	n := newNftablesTestTable(t)
+	if err := n.BanFor(
+		TimedBan{Target: "192.0.2.9", Duration: time.Hour},
+		TimedBan{Target: "192.0.2.10", Duration: time.Hour},
+	); err != nil {
+		t.Fatalf("BanFor(addresses) error = %v", err)
+	}
+	if err := n.BanFor(TimedBan{Target: "192.0.2.0/24", Duration: time.Minute}); err != nil {
+		t.Fatalf("BanFor(subnet) error = %v", err)
+	}
+
+	// An address inside the subnet that has no ban of its own.
+	if err := n.Unban("192.0.2.20"); !errors.Is(err, ErrCovered) {
+		t.Errorf("Unban(covered address) error = %v, want ErrCovered", err)
+	}
+	if err := n.Unban("192.0.2.10"); !errors.Is(err, ErrCovered) {
+		t.Errorf("Unban(banned address in subnet) error = %v, want ErrCovered", err)
+	}
+
+	// The subnet ban expires before the address bans inside it.
+	if err := n.Unban("192.0.2.0/24"); err != nil {
+		t.Fatalf("Unban(subnet) error = %v", err)
+	}
+	elements, err := n.conn.GetSetElements(nftablesSet("blocked_ipv4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	intervals := nftablesIntervals(elements)
+	if len(intervals) != 1 || intervals[0].String() != "192.0.2.9-192.0.2.9" {
+		t.Errorf("address bans after subnet unban = %v, want 192.0.2.9", intervals)
+	}
+	if err := n.Unban("192.0.2.9"); err != nil {
+		t.Errorf("Unban(address) error = %v", err)
+	}
+}
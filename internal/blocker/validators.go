@@ -3,21 +3,46 @@ package blocker
 import (
 	"errors"
 	"fmt"
-	"net"
+	"net/netip"
 	"path/filepath"
 	"strings"
 )
 
-func validateIP(ip string) error {
-	if ip == "" {
-		return fmt.Errorf("empty IP")
+// NormalizeTarget validates a ban target, either a single address or a CIDR
+// prefix, and returns its canonical form. Host bits of a prefix are cleared
+// and a full-length prefix is returned as a plain address.
+func NormalizeTarget(target string) (string, error) {
+	if target == "" {
+		return "", fmt.Errorf("empty IP")
 	}
 
-	if net.ParseIP(ip) == nil {
-		return fmt.Errorf("invalid IP: %s", ip)
+	if strings.Contains(target, "/") {
+		prefix, err := netip.ParsePrefix(target)
+		if err != nil {
+			return "", fmt.Errorf("invalid CIDR: %s", target)
+		}
+		prefix = prefix.Masked()
+		if prefix.IsSingleIP() {
+			return prefix.Addr().String(), nil
+		}
+		return prefix.String(), nil
 	}
 
-	return nil
+	addr, err := netip.ParseAddr(target)
+	if err != nil || addr.Zone() != "" {
+		return "", fmt.Errorf("invalid IP: %s", target)
+	}
+	return addr.String(), nil
+}
+
+// IsPrefix reports whether target is a CIDR prefix rather than an address.
+func IsPrefix(target string) bool {
+	return strings.Contains(target, "/")
+}
+
+func validateIP(ip string) error {
+	_, err := NormalizeTarget(ip)
+	return err
 }
 
 func validateConfigPath(pathIn string) error {
@@ -34,6 +34,10 @@ func TestValidateIP(t *testing.T) {
 		{name: "empty", input: "", wantErr: true},
 		{name: "invalid IP", input: "1.1.1", wantErr: true},
 		{name: "valid IP", input: "1.1.1.1", wantErr: false},
+		{name: "IPv4 CIDR", input: "192.0.2.0/24", wantErr: false},
+		{name: "IPv6 CIDR", input: "2001:db8::/64", wantErr: false},
+		{name: "invalid CIDR", input: "192.0.2.0/33", wantErr: true},
+		{name: "zone", input: "fe80::1%eth0", wantErr: true},
 	}
 
 	for _, tt := range tests {
@@ -66,6 +70,24 @@ func TestNftablesSetForIP(t *testing.T) {
 			wantSet:     "blocked_ipv6",
 			wantAddress: "2001:db8::1",
 		},
+		{
+			name:        "IPv4-mapped",
+			ip:          "::ffff:192.0.2.10",
+			wantSet:     "blocked_ipv4",
+			wantAddress: "192.0.2.10",
+		},
+		{
+			name:        "IPv4 CIDR",
+			ip:          "192.0.2.0/24",
+			wantSet:     "blocked_ipv4_net",
+			wantAddress: "192.0.2.0/24",
+		},
+		{
+			name:        "IPv6 CIDR",
+			ip:          "2001:db8::/64",
+			wantSet:     "blocked_ipv6_net",
+			wantAddress: "2001:db8::/64",
+		},
 		{
 			name:    "invalid",
 			ip:      "not-an-ip",
@@ -88,3 +110,30 @@ func TestNftablesSetForIP(t *testing.T) {
 		})
 	}
 }
+
+func TestNormalizeTarget(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{input: "192.0.2.10", want: "192.0.2.10"},
+		{input: "192.0.2.10/24", want: "192.0.2.0/24"},
+		{input: "192.0.2.10/32", want: "192.0.2.10"},
+		{input: "2001:DB8::1:2/64", want: "2001:db8::/64"},
+		{input: "", wantErr: true},
+		{input: "192.0.2.0/", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := NormalizeTarget(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizeTarget(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeTarget(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
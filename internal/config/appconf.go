@@ -12,11 +12,9 @@ import (
 )
 
 func LoadRuleConfig() ([]Rule, error) {
-	const rulesDir = "/etc/banforge/rules.d"
-
 	var cfg Rules
 
-	files, err := os.ReadDir(rulesDir)
+	files, err := os.ReadDir(RulesDir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read rules directory: %w", err)
 	}
@@ -26,12 +24,17 @@ func LoadRuleConfig() ([]Rule, error) {
 			continue
 		}
 
-		filePath := filepath.Join(rulesDir, file.Name())
+		filePath := filepath.Join(RulesDir, file.Name())
 		var fileCfg Rules
 
 		if _, err := toml.DecodeFile(filePath, &fileCfg); err != nil {
 			return nil, fmt.Errorf("failed to parse rule file %s: %w", filePath, err)
 		}
+		for _, rule := range fileCfg.Rules {
+			if err := rule.Validate(); err != nil {
+				return nil, fmt.Errorf("invalid rule file %s: %w", filePath, err)
+			}
+		}
 
 		cfg.Rules = append(cfg.Rules, fileCfg.Rules...)
 	}
@@ -47,6 +50,7 @@ func NewRule(
 	method string,
 	ttl string,
 	maxRetry int,
+	findTime string,
 ) error {
 	if name == "" {
 		return fmt.Errorf("rule name can't be empty")
@@ -56,13 +60,17 @@ func NewRule(
 		Name:        name,
 		ServiceName: serviceName,
 		Path:        path,
-		Status:      status,
-		Method:      method,
+		Status:      SplitList(status),
+		Method:      SplitList(method),
 		BanTime:     ttl,
 		MaxRetry:    maxRetry,
+		FindTime:    findTime,
+	}
+	if err := rule.Validate(); err != nil {
+		return err
 	}
 
-	filePath := filepath.Join("/etc/banforge/rules.d", SanitizeRuleFilename(name)+".toml")
+	filePath := filepath.Join(RulesDir, SanitizeRuleFilename(name)+".toml")
 
 	if _, err := os.Stat(filePath); err == nil {
 		return fmt.Errorf("rule with name '%s' already exists", name)
@@ -112,10 +120,10 @@ func EditRule(name string, serviceName string, path string, status string, metho
 				updatedRule.Path = path
 			}
 			if status != "" {
-				updatedRule.Status = status
+				updatedRule.Status = SplitList(status)
 			}
 			if method != "" {
-				updatedRule.Method = method
+				updatedRule.Method = SplitList(method)
 			}
 			break
 		}
@@ -124,8 +132,11 @@ func EditRule(name string, serviceName string, path string, status string, metho
 	if !found {
 		return fmt.Errorf("rule '%s' not found", name)
 	}
+	if err := updatedRule.Validate(); err != nil {
+		return err
+	}
 
-	filePath := filepath.Join("/etc/banforge/rules.d", SanitizeRuleFilename(name)+".toml")
+	filePath := filepath.Join(RulesDir, SanitizeRuleFilename(name)+".toml")
 	cfg := Rules{Rules: []Rule{*updatedRule}}
 
 	// #nosec G304 - validate by sanitizeRuleFilename
@@ -232,7 +232,7 @@ func TestParseDurationWithYears(t *testing.T) {
 // ============================================
 
 func TestNewRule_EmptyName(t *testing.T) {
-	err := NewRule("", "nginx", "", "", "", "1h", 0)
+	err := NewRule("", "nginx", "", "", "", "1h", 0, "")
 	if err == nil {
 		t.Error("NewRule with empty name should return error")
 	}
@@ -253,7 +253,7 @@ func TestNewRule_DuplicateRule(t *testing.T) {
 	file.Close()
 
 	// Try to create duplicate
-	err := NewRule("test-rule", "nginx", "", "", "", "1h", 0)
+	err := NewRule("test-rule", "nginx", "", "", "", "1h", 0, "")
 	if err == nil {
 		t.Error("NewRule with duplicate name should return error")
 	}
@@ -283,8 +283,8 @@ func TestRule_StructTags(t *testing.T) {
 		Name:        "test-rule",
 		ServiceName: "nginx",
 		Path:        "/admin/*",
-		Status:      "403",
-		Method:      "POST",
+		Status:      StringList{"403"},
+		Method:      StringList{"POST"},
 		MaxRetry:    5,
 		BanTime:     "1h",
 	}
@@ -863,8 +863,8 @@ func TestRule_FullRoundTrip(t *testing.T) {
 				Name:        "nginx-bruteforce",
 				ServiceName: "nginx",
 				Path:        "/admin/*",
-				Status:      "403",
-				Method:      "POST",
+				Status:      StringList{"403"},
+				Method:      StringList{"POST"},
 				MaxRetry:    5,
 				BanTime:     "2h",
 				Action: []Action{
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// IPMatcher matches addresses against a list of single IPs and CIDR prefixes,
+// as used by ignore_ip.
+type IPMatcher struct {
+	prefixes []netip.Prefix
+}
+
+func NewIPMatcher(entries []string) (*IPMatcher, error) {
+	m := &IPMatcher{prefixes: make([]netip.Prefix, 0, len(entries))}
+	for _, entry := range entries {
+		prefix, err := parseIPOrPrefix(entry)
+		if err != nil {
+			return nil, err
+		}
+		m.prefixes = append(m.prefixes, prefix)
+	}
+	return m, nil
+}
+
+// Contains reports whether ip is covered by any entry. Unparsable addresses
+// and a nil matcher never match.
+func (m *IPMatcher) Contains(ip string) bool {
+	if m == nil {
+		return false
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range m.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Overlaps reports whether target, an address or a CIDR prefix, shares any
+// address with an entry. It is used to refuse subnet bans that would cover
+// an ignored address.
+func (m *IPMatcher) Overlaps(target string) bool {
+	if m == nil {
+		return false
+	}
+	prefix, err := parseIPOrPrefix(target)
+	if err != nil {
+		return false
+	}
+	for _, p := range m.prefixes {
+		if p.Overlaps(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseIPOrPrefix(entry string) (netip.Prefix, error) {
+	entry = strings.TrimSpace(entry)
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid CIDR %q: %w", entry, err)
+		}
+		addr := prefix.Addr().Unmap()
+		bits := prefix.Bits()
+		if prefix.Addr().Is4In6() {
+			bits -= 96
+		}
+		return netip.PrefixFrom(addr, bits).Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid IP %q: %w", entry, err)
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
@@ -0,0 +1,183 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StringList is a rule field that accepts either a single string or a list
+// of strings in TOML, e.g. status = "404" or status = ["401", "403"].
+type StringList []string
+
+// SplitList turns a comma separated CLI value into a StringList.
+func SplitList(s string) StringList {
+	var list StringList
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func (l *StringList) UnmarshalTOML(v any) error {
+	switch v := v.(type) {
+	case string:
+		*l = nil
+		if v = strings.TrimSpace(v); v != "" {
+			*l = StringList{v}
+		}
+	case int64:
+		*l = StringList{strconv.FormatInt(v, 10)}
+	case []any:
+		list := make(StringList, 0, len(v))
+		for _, item := range v {
+			switch item := item.(type) {
+			case string:
+				list = append(list, strings.TrimSpace(item))
+			case int64:
+				list = append(list, strconv.FormatInt(item, 10))
+			default:
+				return fmt.Errorf("unsupported list item %v (%T)", item, item)
+			}
+		}
+		*l = list
+	default:
+		return fmt.Errorf("expected a string or a list of strings, got %T", v)
+	}
+	return nil
+}
+
+// MarshalTOML keeps single values as plain strings so rule files written by
+// the CLI look the same as before lists were supported.
+func (l StringList) MarshalTOML() ([]byte, error) {
+	switch len(l) {
+	case 0:
+		return []byte(`""`), nil
+	case 1:
+		return json.Marshal(l[0])
+	default:
+		return json.Marshal([]string(l))
+	}
+}
+
+func (l StringList) String() string {
+	return strings.Join(l, ", ")
+}
+
+// MatchStatus reports whether status matches any of the rule's status
+// patterns. An empty status list matches everything.
+func (r Rule) MatchStatus(status string) bool {
+	if len(r.Status) == 0 {
+		return true
+	}
+	for _, pattern := range r.Status {
+		if matchStatus(pattern, status) {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultStatus returns the statuses a rule without status matches on the
+// service's entries; nil matches everything. The ssh parser reports several
+// events for one login attempt, so its rules count failed authentications
+// only unless they list the other events.
+func (s Service) DefaultStatus() StringList {
+	if s.Pattern == "" && s.ParserName() == "ssh" {
+		return StringList{"Failed"}
+	}
+	return nil
+}
+
+// MatchMethod reports whether method is one of the rule's methods. An empty
+// method list matches everything.
+func (r Rule) MatchMethod(method string) bool {
+	if len(r.Method) == 0 {
+		return true
+	}
+	for _, m := range r.Method {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchStatus supports a status class ("4xx"), an inclusive range
+// ("400-499") and exact values. Exact values need not be numeric, so event
+// types such as sshd's "Failed" or "InvalidUser" work too, in any case.
+func matchStatus(pattern string, status string) bool {
+	if class, ok := statusClass(pattern); ok {
+		return len(status) == 3 && status[0] == class && isDigits(status)
+	}
+	if lo, hi, ok := statusRange(pattern); ok {
+		code, err := strconv.Atoi(status)
+		return err == nil && isDigits(status) && code >= lo && code <= hi
+	}
+	return strings.EqualFold(pattern, status)
+}
+
+func statusClass(pattern string) (byte, bool) {
+	if len(pattern) != 3 || !strings.EqualFold(pattern[1:], "xx") {
+		return 0, false
+	}
+	return pattern[0], true
+}
+
+func statusRange(pattern string) (int, int, bool) {
+	loStr, hiStr, found := strings.Cut(pattern, "-")
+	if !found || !isDigits(loStr) || !isDigits(hiStr) {
+		return 0, 0, false
+	}
+	lo, errLo := strconv.Atoi(loStr)
+	hi, errHi := strconv.Atoi(hiStr)
+	if errLo != nil || errHi != nil {
+		return 0, 0, false
+	}
+	return lo, hi, true
+}
+
+func validateStatusPattern(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("empty status")
+	}
+	if class, ok := statusClass(pattern); ok {
+		if class < '1' || class > '5' {
+			return fmt.Errorf("invalid status class %q", pattern)
+		}
+		return nil
+	}
+	if lo, hi, ok := statusRange(pattern); ok {
+		if lo < 100 || hi > 599 || lo > hi {
+			return fmt.Errorf("invalid status range %q", pattern)
+		}
+	}
+	return nil
+}
+
+func validateMethod(method string) error {
+	if method == "" {
+		return fmt.Errorf("empty method")
+	}
+	for _, c := range method {
+		if (c < 'A' || c > 'Z') && (c < 'a' || c > 'z') {
+			return fmt.Errorf("invalid method %q", method)
+		}
+	}
+	return nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -14,6 +14,7 @@ var DetectedFirewall string
 const (
 	ConfigDir  = "/etc/banforge"
 	ConfigFile = "config.toml"
+	RulesDir   = ConfigDir + "/rules.d"
 )
 
 func createFileWithPermissions(path string, perm os.FileMode) error {
@@ -55,11 +56,10 @@ func CreateConf() error {
 	}
 	fmt.Printf("Config file created: %s\n", configPath)
 
-	rulesDir := filepath.Join(ConfigDir, "rules.d")
-	if err := os.MkdirAll(rulesDir, 0750); err != nil {
+	if err := os.MkdirAll(RulesDir, 0750); err != nil {
 		return fmt.Errorf("failed to create rules directory: %w", err)
 	}
-	fmt.Printf("Rules directory created: %s\n", rulesDir)
+	fmt.Printf("Rules directory created: %s\n", RulesDir)
 
 	bansDBDir := filepath.Dir("/var/lib/banforge/bans.db")
 	if err := os.MkdirAll(bansDBDir, 0750); err != nil {
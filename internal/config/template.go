@@ -4,6 +4,20 @@ const Base_config = `
 # This is a TOML config file for BanForge
 # https://github.com/d3m0k1d/BanForge
 
+# Addresses and CIDR ranges that are never banned
+ignore_ip = ["127.0.0.1/8", "::1"]
+
+# "first" stops at the first matching rule, "all" evaluates every matching
+# rule and applies the longest ban
+rule_match = "first"
+
+# Ban the whole /24 (IPv4) or /64 (IPv6) once this many of its addresses
+# are banned at the same time
+[subnet_ban]
+enabled = false
+threshold = 5
+ban_time = "1d"
+
 [storage]
 retention_time = "2d"
 cleanup_interval = "1h"
@@ -8,8 +8,18 @@ type Firewall struct {
 type Service struct {
 	Name    string `toml:"name"`
 	Logging string `toml:"logging"`
-	LogPath string `toml:"log_path"`
+	LogPath string `toml:"log_path"` // file path, or journald unit
 	Enabled bool   `toml:"enabled"`
+	Parser  string `toml:"parser"` // built-in parser, defaults to name
+
+	// Pattern is a Go regular expression used instead of a built-in parser.
+	// Its named groups fill the log entry; "ip" is required.
+	Pattern string `toml:"pattern"`
+
+	// journald only: select entries by SYSLOG_IDENTIFIER and by journalctl
+	// match expressions ("_COMM=sshd", "+"), with or without a unit.
+	SyslogIdentifier StringList `toml:"syslog_identifier"`
+	JournalMatch     []string   `toml:"journal_match"`
 }
 
 type Storage struct {
@@ -17,27 +27,72 @@ type Storage struct {
 	CleanupInterval string `toml:"cleanup_interval"`
 }
 
+// SubnetBan escalates to a ban of the surrounding /24 (IPv4) or /64 (IPv6)
+// once Threshold addresses from that prefix are banned at the same time.
+type SubnetBan struct {
+	Enabled   bool   `toml:"enabled"`
+	Threshold int    `toml:"threshold"`
+	BanTime   string `toml:"ban_time"`
+}
+
 type Config struct {
-	Firewall Firewall  `toml:"firewall"`
-	Metrics  Metrics   `toml:"metrics"`
-	Service  []Service `toml:"service"`
-	Storage  Storage   `toml:"storage"`
+	Firewall  Firewall  `toml:"firewall"`
+	Metrics   Metrics   `toml:"metrics"`
+	Service   []Service `toml:"service"`
+	Storage   Storage   `toml:"storage"`
+	IgnoreIP  []string  `toml:"ignore_ip"`
+	RuleMatch string    `toml:"rule_match"`
+	SubnetBan SubnetBan `toml:"subnet_ban"`
+
+	// Mode "monitor" records bans without applying them to the firewall.
+	// MonitorActions runs rule actions for those bans too.
+	Mode           string `toml:"mode"`
+	MonitorActions bool   `toml:"monitor_actions"`
 }
 
+// Values of Config.RuleMatch.
+const (
+	// RuleMatchFirst stops at the first rule that matches a log line.
+	RuleMatchFirst = "first"
+	// RuleMatchAll evaluates every matching rule; the longest ban wins.
+	RuleMatchAll = "all"
+)
+
+// Values of Config.Mode and Rule.Mode.
+const (
+	// ModeEnforce bans at the firewall.
+	ModeEnforce = "enforce"
+	// ModeMonitor only records, logs and counts the bans that would be made.
+	ModeMonitor = "monitor"
+)
+
 // Rules
 type Rules struct {
 	Rules []Rule `toml:"rule"`
 }
 
 type Rule struct {
-	Name        string   `toml:"name"`
-	ServiceName string   `toml:"service"`
-	Path        string   `toml:"path"`
-	Status      string   `toml:"status"`
-	Method      string   `toml:"method"`
-	MaxRetry    int      `toml:"max_retry"`
-	BanTime     string   `toml:"ban_time"`
-	Action      []Action `toml:"action"`
+	Name        string     `toml:"name"`
+	ServiceName string     `toml:"service"`
+	Path        string     `toml:"path"`
+	Status      StringList `toml:"status"` // "404", "4xx", "400-499" or a list of these
+	Method      StringList `toml:"method"`
+	MaxRetry    int        `toml:"max_retry"`
+	FindTime    string     `toml:"find_time"`
+	BanTime     string     `toml:"ban_time"`
+	IgnoreIP    []string   `toml:"ignore_ip"` // replaces the global ignore_ip when set
+	Action      []Action   `toml:"action"`
+	Priority    int        `toml:"priority"` // higher runs first, ties keep file order
+	Mode        string     `toml:"mode"`     // "monitor" to trial the rule; a monitor daemon overrides "enforce"
+
+	// Go regular expressions, matched in addition to path and the other fields.
+	PathRegex      string `toml:"path_regex"`
+	UserAgentRegex string `toml:"user_agent_regex"`
+
+	// Repeat offender escalation, see Rule.BanDuration.
+	BanTimeMultiplier float64  `toml:"ban_time_multiplier"`
+	BanTimeEscalation []string `toml:"ban_time_escalation"`
+	MaxBanTime        string   `toml:"max_ban_time"`
 }
 
 type Metrics struct {
@@ -1,18 +1,37 @@
 package config
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
 
 const (
 	defaultRetentionTime   = "2d"
 	defaultCleanupInterval = "1h"
+	defaultFindTime        = "10m"
+	defaultSubnetThreshold = 5
+	defaultSubnetBanTime   = "1d"
 )
 
+var defaultIgnoreIP = []string{"127.0.0.1/8", "::1"}
+
 func newConfigWithDefaults() *Config {
 	return &Config{
 		Storage: Storage{
 			RetentionTime:   defaultRetentionTime,
 			CleanupInterval: defaultCleanupInterval,
 		},
+		IgnoreIP:  append([]string(nil), defaultIgnoreIP...),
+		RuleMatch: RuleMatchFirst,
+		Mode:      ModeEnforce,
+		SubnetBan: SubnetBan{
+			Threshold: defaultSubnetThreshold,
+			BanTime:   defaultSubnetBanTime,
+		},
 	}
 }
 
@@ -20,10 +39,135 @@ func (c Config) Validate() error {
 	if err := c.Storage.Validate(); err != nil {
 		return fmt.Errorf("storage: %w", err)
 	}
+	if _, err := NewIPMatcher(c.IgnoreIP); err != nil {
+		return fmt.Errorf("ignore_ip: %w", err)
+	}
+	for i, svc := range c.Service {
+		if err := svc.Validate(); err != nil {
+			return fmt.Errorf("service[%d]: %w", i, err)
+		}
+	}
+	if c.RuleMatch != RuleMatchFirst && c.RuleMatch != RuleMatchAll {
+		return fmt.Errorf("rule_match must be %q or %q, got %q", RuleMatchFirst, RuleMatchAll, c.RuleMatch)
+	}
+	if err := c.SubnetBan.Validate(); err != nil {
+		return fmt.Errorf("subnet_ban: %w", err)
+	}
+	if c.Mode != ModeEnforce && c.Mode != ModeMonitor {
+		return fmt.Errorf("mode must be %q or %q, got %q", ModeEnforce, ModeMonitor, c.Mode)
+	}
 
 	return nil
 }
 
+// Validate checks enabled services only, so a half-written disabled entry
+// does not stop the daemon.
+func (s Service) Validate() error {
+	if !s.Enabled {
+		return nil
+	}
+	if s.Name == "" {
+		return fmt.Errorf("name can't be empty")
+	}
+	if s.Logging != "file" && s.Logging != "journald" {
+		return fmt.Errorf("%s: logging must be \"file\" or \"journald\", got %q", s.Name, s.Logging)
+	}
+	if s.Logging == "file" && (len(s.SyslogIdentifier) > 0 || len(s.JournalMatch) > 0) {
+		return fmt.Errorf("%s: syslog_identifier and journal_match need logging = \"journald\"", s.Name)
+	}
+	if s.LogPath == "" && len(s.SyslogIdentifier) == 0 && len(s.JournalMatch) == 0 {
+		if s.Logging == "journald" {
+			return fmt.Errorf("%s: set log_path, syslog_identifier or journal_match", s.Name)
+		}
+		return fmt.Errorf("%s: log_path can't be empty", s.Name)
+	}
+	if s.Pattern != "" && s.Parser != "" {
+		return fmt.Errorf("%s: set either parser or pattern, not both", s.Name)
+	}
+	if s.Pattern != "" {
+		if err := validateServicePattern(s.Pattern); err != nil {
+			return fmt.Errorf("%s: %w", s.Name, err)
+		}
+	}
+	for _, ident := range s.SyslogIdentifier {
+		if !JournalIdentifierPattern.MatchString(ident) {
+			return fmt.Errorf("%s: invalid syslog_identifier %q", s.Name, ident)
+		}
+	}
+	for _, match := range s.JournalMatch {
+		if match != "+" && !JournalMatchPattern.MatchString(match) {
+			return fmt.Errorf("%s: invalid journal_match %q, want FIELD=value or \"+\"", s.Name, match)
+		}
+	}
+	return nil
+}
+
+// ParserName returns the built-in parser the service uses.
+func (s Service) ParserName() string {
+	if s.Parser != "" {
+		return s.Parser
+	}
+	return s.Name
+}
+
+// PatternGroups are the named groups a service pattern may use.
+var PatternGroups = []string{"ip", "path", "method", "status", "user", "user_agent", "time"}
+
+func validateServicePattern(pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid pattern: %w", err)
+	}
+	hasIP := false
+	for _, name := range re.SubexpNames() {
+		switch {
+		case name == "":
+		case name == "ip":
+			hasIP = true
+		case !slices.Contains(PatternGroups, name):
+			return fmt.Errorf("pattern: unknown group %q, want one of %s", name, strings.Join(PatternGroups, ", "))
+		}
+	}
+	if !hasIP {
+		return fmt.Errorf("pattern: missing (?P<ip>...) group")
+	}
+	return nil
+}
+
+// JournalIdentifierPattern and JournalMatchPattern check the syslog
+// identifiers and FIELD=value matches of a journald service. The journald
+// parser uses them too, so both accept the same filters.
+var (
+	JournalIdentifierPattern = regexp.MustCompile(`^[A-Za-z0-9._@][A-Za-z0-9._@-]*$`)
+	// Journal field names are upper case letters, digits and underscores.
+	JournalMatchPattern = regexp.MustCompile(`^[A-Z0-9_]+=`)
+)
+
+func (s SubnetBan) Validate() error {
+	if !s.Enabled {
+		return nil
+	}
+	if s.Threshold < 2 {
+		return fmt.Errorf("threshold must be at least 2")
+	}
+	if _, err := s.BanDuration(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// BanDuration returns how long a whole prefix stays banned.
+func (s SubnetBan) BanDuration() (time.Duration, error) {
+	duration, err := ParseDurationWithYears(s.BanTime)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ban_time %q: %w", s.BanTime, err)
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("ban_time must be greater than zero")
+	}
+	return duration, nil
+}
+
 func (s Storage) Validate() error {
 	retention, err := ParseDurationWithYears(s.RetentionTime)
 	if err != nil {
@@ -43,3 +187,121 @@ func (s Storage) Validate() error {
 
 	return nil
 }
+
+// Validate checks the fields of a rule that can be verified without running
+// it, so that LoadRuleConfig rejects a broken rule file up front.
+func (r Rule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("rule name can't be empty")
+	}
+	if r.ServiceName == "" {
+		return fmt.Errorf("rule %q: service can't be empty", r.Name)
+	}
+	for _, status := range r.Status {
+		if err := validateStatusPattern(status); err != nil {
+			return fmt.Errorf("rule %q: status: %w", r.Name, err)
+		}
+	}
+	for _, method := range r.Method {
+		if err := validateMethod(method); err != nil {
+			return fmt.Errorf("rule %q: method: %w", r.Name, err)
+		}
+	}
+	if _, err := regexp.Compile(r.PathRegex); err != nil {
+		return fmt.Errorf("rule %q: path_regex: %w", r.Name, err)
+	}
+	if _, err := regexp.Compile(r.UserAgentRegex); err != nil {
+		return fmt.Errorf("rule %q: user_agent_regex: %w", r.Name, err)
+	}
+	if _, err := r.FindTimeDuration(); err != nil {
+		return fmt.Errorf("rule %q: %w", r.Name, err)
+	}
+	if _, err := r.BanDuration(0); err != nil {
+		return fmt.Errorf("rule %q: %w", r.Name, err)
+	}
+	// BanDuration only parses the entries a ban reaches, so check the ones
+	// used for repeat offenders now instead of on their next offence.
+	for _, entry := range r.BanTimeEscalation {
+		if _, err := ParseDurationWithYears(entry); err != nil {
+			return fmt.Errorf("rule %q: invalid ban_time_escalation entry %q: %w", r.Name, entry, err)
+		}
+	}
+	if r.MaxBanTime != "" {
+		if _, err := ParseDurationWithYears(r.MaxBanTime); err != nil {
+			return fmt.Errorf("rule %q: invalid max_ban_time %q: %w", r.Name, r.MaxBanTime, err)
+		}
+	}
+	if r.BanTimeMultiplier != 0 && r.BanTimeMultiplier < 1 {
+		return fmt.Errorf("rule %q: ban_time_multiplier must be at least 1, got %v", r.Name, r.BanTimeMultiplier)
+	}
+	if _, err := NewIPMatcher(r.IgnoreIP); err != nil {
+		return fmt.Errorf("rule %q: ignore_ip: %w", r.Name, err)
+	}
+	if r.Mode != "" && r.Mode != ModeEnforce && r.Mode != ModeMonitor {
+		return fmt.Errorf("rule %q: mode must be %q or %q, got %q", r.Name, ModeEnforce, ModeMonitor, r.Mode)
+	}
+	return nil
+}
+
+// FindTimeDuration returns the window in which max_retry hits are counted,
+// falling back to defaultFindTime when the rule does not set find_time.
+func (r Rule) FindTimeDuration() (time.Duration, error) {
+	findTime := r.FindTime
+	if findTime == "" {
+		findTime = defaultFindTime
+	}
+
+	duration, err := ParseDurationWithYears(findTime)
+	if err != nil {
+		return 0, fmt.Errorf("invalid find_time %q: %w", findTime, err)
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("find_time must be greater than zero")
+	}
+
+	return duration, nil
+}
+
+// BanDuration returns how long to ban an address that has already been banned
+// previousBans times. An explicit ban_time_escalation list takes precedence
+// over ban_time_multiplier; the result is capped by max_ban_time when set.
+func (r Rule) BanDuration(previousBans int) (time.Duration, error) {
+	var duration time.Duration
+	switch {
+	case len(r.BanTimeEscalation) > 0:
+		step := min(max(previousBans, 0), len(r.BanTimeEscalation)-1)
+		d, err := ParseDurationWithYears(r.BanTimeEscalation[step])
+		if err != nil {
+			return 0, fmt.Errorf(
+				"invalid ban_time_escalation entry %q: %w",
+				r.BanTimeEscalation[step],
+				err,
+			)
+		}
+		duration = d
+	default:
+		d, err := ParseDurationWithYears(r.BanTime)
+		if err != nil {
+			return 0, fmt.Errorf("invalid ban_time %q: %w", r.BanTime, err)
+		}
+		duration = d
+		if r.BanTimeMultiplier > 1 && previousBans > 0 {
+			scaled := float64(d) * math.Pow(r.BanTimeMultiplier, float64(previousBans))
+			if scaled >= math.MaxInt64 {
+				duration = time.Duration(math.MaxInt64)
+			} else {
+				duration = time.Duration(scaled)
+			}
+		}
+	}
+
+	if r.MaxBanTime != "" {
+		maxBanTime, err := ParseDurationWithYears(r.MaxBanTime)
+		if err != nil {
+			return 0, fmt.Errorf("invalid max_ban_time %q: %w", r.MaxBanTime, err)
+		}
+		duration = min(duration, maxBanTime)
+	}
+
+	return duration, nil
+}
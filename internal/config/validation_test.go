@@ -1,8 +1,11 @@
 package config
 
 import (
+	"bytes"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
@@ -115,3 +118,475 @@ func TestStorageValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestRuleFindTimeDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		findTime string
+		want     time.Duration
+		wantErr  bool
+	}{
+		{name: "default", findTime: "", want: 10 * time.Minute},
+		{name: "minutes", findTime: "30m", want: 30 * time.Minute},
+		{name: "days", findTime: "1d", want: 24 * time.Hour},
+		{name: "invalid", findTime: "soon", wantErr: true},
+		{name: "negative", findTime: "-1h", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Rule{FindTime: tt.findTime}.FindTimeDuration()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FindTimeDuration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("FindTimeDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleBanDuration(t *testing.T) {
+	tests := []struct {
+		name         string
+		rule         Rule
+		previousBans int
+		want         time.Duration
+		wantErr      bool
+	}{
+		{
+			name: "fixed ban time",
+			rule: Rule{BanTime: "1h"},
+			want: time.Hour,
+		},
+		{
+			name:         "fixed ban time ignores history",
+			rule:         Rule{BanTime: "1h"},
+			previousBans: 3,
+			want:         time.Hour,
+		},
+		{
+			name:         "multiplier",
+			rule:         Rule{BanTime: "1h", BanTimeMultiplier: 2},
+			previousBans: 3,
+			want:         8 * time.Hour,
+		},
+		{
+			name:         "multiplier capped",
+			rule:         Rule{BanTime: "1h", BanTimeMultiplier: 10, MaxBanTime: "1d"},
+			previousBans: 5,
+			want:         24 * time.Hour,
+		},
+		{
+			name:         "multiplier overflow capped",
+			rule:         Rule{BanTime: "1y", BanTimeMultiplier: 10, MaxBanTime: "1y"},
+			previousBans: 100,
+			want:         365 * 24 * time.Hour,
+		},
+		{
+			name: "escalation first ban",
+			rule: Rule{BanTime: "5m", BanTimeEscalation: []string{"1h", "1d", "30d", "1y"}},
+			want: time.Hour,
+		},
+		{
+			name:         "escalation third ban",
+			rule:         Rule{BanTimeEscalation: []string{"1h", "1d", "30d", "1y"}},
+			previousBans: 2,
+			want:         30 * 24 * time.Hour,
+		},
+		{
+			name:         "escalation stays on last step",
+			rule:         Rule{BanTimeEscalation: []string{"1h", "1d", "30d", "1y"}},
+			previousBans: 10,
+			want:         365 * 24 * time.Hour,
+		},
+		{
+			name:    "invalid escalation entry",
+			rule:    Rule{BanTimeEscalation: []string{"forever"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid max ban time",
+			rule:    Rule{BanTime: "1h", MaxBanTime: "never"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.rule.BanDuration(tt.previousBans)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BanDuration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("BanDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPMatcher(t *testing.T) {
+	m, err := NewIPMatcher([]string{"127.0.0.1/8", "::1", "192.0.2.10", "2001:db8::/32", "::ffff:198.51.100.0/120"})
+	if err != nil {
+		t.Fatalf("NewIPMatcher() error = %v", err)
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "127.0.0.1", want: true},
+		{ip: "127.1.2.3", want: true},
+		{ip: "::1", want: true},
+		{ip: "192.0.2.10", want: true},
+		{ip: "192.0.2.11", want: false},
+		{ip: "2001:db8:1::5", want: true},
+		{ip: "2001:db9::5", want: false},
+		{ip: "::ffff:192.0.2.10", want: true},
+		{ip: "198.51.100.77", want: true},
+		{ip: "not-an-ip", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := m.Contains(tt.ip); got != tt.want {
+				t.Errorf("Contains(%q) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+
+	var nilMatcher *IPMatcher
+	if nilMatcher.Contains("127.0.0.1") {
+		t.Error("nil matcher must not match")
+	}
+}
+
+func TestConfigValidateIgnoreIP(t *testing.T) {
+	cfg := newConfigWithDefaults()
+	if len(cfg.IgnoreIP) == 0 {
+		t.Fatal("default ignore_ip is empty")
+	}
+
+	if _, err := toml.Decode(`ignore_ip = ["10.0.0.0/8", "bogus"]`, cfg); err != nil {
+		t.Fatal(err)
+	}
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "ignore_ip") {
+		t.Fatalf("Validate() error = %v, want ignore_ip error", err)
+	}
+}
+
+func TestIPMatcherOverlaps(t *testing.T) {
+	m, err := NewIPMatcher([]string{"192.0.2.10", "2001:db8::/48"})
+	if err != nil {
+		t.Fatalf("NewIPMatcher() error = %v", err)
+	}
+
+	tests := []struct {
+		target string
+		want   bool
+	}{
+		{target: "192.0.2.0/24", want: true},
+		{target: "192.0.3.0/24", want: false},
+		{target: "192.0.2.10", want: true},
+		{target: "2001:db8:0:1::/64", want: true},
+		{target: "2001:db8:1::/64", want: false},
+		{target: "bogus/24", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			if got := m.Overlaps(tt.target); got != tt.want {
+				t.Errorf("Overlaps(%q) = %v, want %v", tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubnetBanValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       SubnetBan
+		wantErr bool
+	}{
+		{name: "disabled ignores values", s: SubnetBan{Threshold: 0, BanTime: "bogus"}},
+		{name: "valid", s: SubnetBan{Enabled: true, Threshold: 5, BanTime: "1d"}},
+		{name: "threshold too low", s: SubnetBan{Enabled: true, Threshold: 1, BanTime: "1d"}, wantErr: true},
+		{name: "invalid ban_time", s: SubnetBan{Enabled: true, Threshold: 5, BanTime: "soon"}, wantErr: true},
+		{name: "zero ban_time", s: SubnetBan{Enabled: true, Threshold: 5, BanTime: "0s"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.s.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStringListTOML(t *testing.T) {
+	tests := []struct {
+		name string
+		toml string
+		want StringList
+	}{
+		{name: "single", toml: `status = "4xx"`, want: StringList{"4xx"}},
+		{name: "empty", toml: `status = ""`, want: nil},
+		{name: "list", toml: `status = ["401", "403"]`, want: StringList{"401", "403"}},
+		{name: "integer", toml: `status = 404`, want: StringList{"404"}},
+		{name: "integer list", toml: `status = [401, "5xx"]`, want: StringList{"401", "5xx"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rule Rule
+			if _, err := toml.Decode(tt.toml, &rule); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !slices.Equal(rule.Status, tt.want) {
+				t.Errorf("Status = %q, want %q", rule.Status, tt.want)
+			}
+
+			var buf bytes.Buffer
+			if err := toml.NewEncoder(&buf).Encode(rule); err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			var decoded Rule
+			if _, err := toml.Decode(buf.String(), &decoded); err != nil {
+				t.Fatalf("Decode() of encoded rule error = %v\n%s", err, buf.String())
+			}
+			if !slices.Equal(decoded.Status, tt.want) {
+				t.Errorf("round trip Status = %q, want %q", decoded.Status, tt.want)
+			}
+		})
+	}
+
+	var rule Rule
+	if _, err := toml.Decode(`status = true`, &rule); err == nil {
+		t.Error("Decode() expected error for boolean status")
+	}
+}
+
+func TestRuleMatchStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		status StringList
+		input  string
+		want   bool
+	}{
+		{name: "no status matches all", status: nil, input: "200", want: true},
+		{name: "exact", status: StringList{"404"}, input: "404", want: true},
+		{name: "exact mismatch", status: StringList{"404"}, input: "403", want: false},
+		{name: "class", status: StringList{"4xx"}, input: "418", want: true},
+		{name: "class upper case", status: StringList{"5XX"}, input: "503", want: true},
+		{name: "class mismatch", status: StringList{"4xx"}, input: "500", want: false},
+		{name: "range low bound", status: StringList{"400-499"}, input: "400", want: true},
+		{name: "range high bound", status: StringList{"400-499"}, input: "499", want: true},
+		{name: "range outside", status: StringList{"400-499"}, input: "500", want: false},
+		{name: "list", status: StringList{"401", "403"}, input: "403", want: true},
+		{name: "list mismatch", status: StringList{"401", "403"}, input: "404", want: false},
+		{name: "sshd event", status: StringList{"Failed"}, input: "Failed", want: true},
+		{name: "sshd event list", status: StringList{"Failed", "InvalidUser"}, input: "InvalidUser", want: true},
+		{name: "event type ignores case", status: StringList{"invaliduser"}, input: "InvalidUser", want: true},
+		{name: "event type mismatch", status: StringList{"Failed"}, input: "NoIdentification", want: false},
+		{name: "class needs numeric status", status: StringList{"4xx"}, input: "4ab", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Rule{Status: tt.status}
+			if got := r.MatchStatus(tt.input); got != tt.want {
+				t.Errorf("MatchStatus(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleMatchMethod(t *testing.T) {
+	r := Rule{Method: StringList{"POST", "put"}}
+	for method, want := range map[string]bool{"POST": true, "PUT": true, "GET": false} {
+		if got := r.MatchMethod(method); got != want {
+			t.Errorf("MatchMethod(%q) = %v, want %v", method, got, want)
+		}
+	}
+	if !(Rule{}).MatchMethod("DELETE") {
+		t.Error("rule without method must match every method")
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	valid := Rule{Name: "scan", ServiceName: "nginx", BanTime: "1h"}
+
+	tests := []struct {
+		name    string
+		modify  func(r *Rule)
+		wantErr string
+	}{
+		{name: "valid", modify: func(r *Rule) {}},
+		{name: "status forms", modify: func(r *Rule) { r.Status = StringList{"4xx", "500-599", "301"} }},
+		{name: "empty name", modify: func(r *Rule) { r.Name = "" }, wantErr: "name"},
+		{name: "empty service", modify: func(r *Rule) { r.ServiceName = "" }, wantErr: "service"},
+		{name: "bad class", modify: func(r *Rule) { r.Status = StringList{"9xx"} }, wantErr: "status"},
+		{name: "reversed range", modify: func(r *Rule) { r.Status = StringList{"499-400"} }, wantErr: "status"},
+		{name: "out of range", modify: func(r *Rule) { r.Status = StringList{"0-999"} }, wantErr: "status"},
+		{name: "empty status item", modify: func(r *Rule) { r.Status = StringList{""} }, wantErr: "status"},
+		{name: "bad method", modify: func(r *Rule) { r.Method = StringList{"GET POST"} }, wantErr: "method"},
+		{name: "bad path_regex", modify: func(r *Rule) { r.PathRegex = "(" }, wantErr: "path_regex"},
+		{name: "bad find_time", modify: func(r *Rule) { r.FindTime = "soon" }, wantErr: "find_time"},
+		{name: "missing ban_time", modify: func(r *Rule) { r.BanTime = "" }, wantErr: "ban_time"},
+		{name: "bad ignore_ip", modify: func(r *Rule) { r.IgnoreIP = []string{"x"} }, wantErr: "ignore_ip"},
+		{name: "escalation", modify: func(r *Rule) { r.BanTimeEscalation = []string{"1h", "1d"}; r.MaxBanTime = "30d" }},
+		{name: "bad later escalation entry", modify: func(r *Rule) { r.BanTimeEscalation = []string{"1h", "1dd"} }, wantErr: "ban_time_escalation"},
+		{name: "bad max_ban_time with escalation", modify: func(r *Rule) { r.BanTimeEscalation = []string{"1h"}; r.MaxBanTime = "never" }, wantErr: "max_ban_time"},
+		{name: "multiplier", modify: func(r *Rule) { r.BanTimeMultiplier = 1.5 }},
+		{name: "multiplier below one", modify: func(r *Rule) { r.BanTimeMultiplier = 0.5 }, wantErr: "ban_time_multiplier"},
+		{name: "negative multiplier", modify: func(r *Rule) { r.BanTimeMultiplier = -2 }, wantErr: "ban_time_multiplier"},
+		{name: "monitor mode", modify: func(r *Rule) { r.Mode = ModeMonitor }},
+		{name: "enforce mode", modify: func(r *Rule) { r.Mode = ModeEnforce }},
+		{name: "bad mode", modify: func(r *Rule) { r.Mode = "dry-run" }, wantErr: "mode"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid
+			tt.modify(&r)
+			err := r.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want %s error", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigValidateRuleMatch(t *testing.T) {
+	for _, mode := range []string{RuleMatchFirst, RuleMatchAll} {
+		cfg := newConfigWithDefaults()
+		cfg.RuleMatch = mode
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("Validate() with rule_match %q error = %v", mode, err)
+		}
+	}
+
+	cfg := newConfigWithDefaults()
+	if cfg.RuleMatch != RuleMatchFirst {
+		t.Errorf("default rule_match = %q, want %q", cfg.RuleMatch, RuleMatchFirst)
+	}
+	cfg.RuleMatch = "strictest"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "rule_match") {
+		t.Fatalf("Validate() error = %v, want rule_match error", err)
+	}
+}
+
+func TestConfigValidateMode(t *testing.T) {
+	for _, mode := range []string{ModeEnforce, ModeMonitor} {
+		cfg := newConfigWithDefaults()
+		cfg.Mode = mode
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("Validate() with mode %q error = %v", mode, err)
+		}
+	}
+
+	cfg := newConfigWithDefaults()
+	if cfg.Mode != ModeEnforce {
+		t.Errorf("default mode = %q, want %q", cfg.Mode, ModeEnforce)
+	}
+	cfg.Mode = ""
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "mode") {
+		t.Fatalf("Validate() error = %v, want mode error", err)
+	}
+}
+
+func TestServiceValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		svc     Service
+		wantErr bool
+	}{
+		{name: "file", svc: Service{Name: "nginx", Logging: "file", LogPath: "/var/log/nginx/access.log", Enabled: true}},
+		{name: "journald", svc: Service{Name: "ssh", Logging: "journald", LogPath: "sshd", Enabled: true}},
+		{name: "disabled is not checked", svc: Service{Name: "nginx", Logging: "syslog"}},
+		{name: "bad logging", svc: Service{Name: "nginx", Logging: "syslog", LogPath: "/x", Enabled: true}, wantErr: true},
+		{name: "no name", svc: Service{Logging: "file", LogPath: "/x", Enabled: true}, wantErr: true},
+		{name: "no log_path", svc: Service{Name: "nginx", Logging: "file", Enabled: true}, wantErr: true},
+		{
+			name: "journald identifier only",
+			svc:  Service{Name: "ssh", Logging: "journald", SyslogIdentifier: StringList{"sshd", "sshd-session"}, Enabled: true},
+		},
+		{
+			name: "journald match only",
+			svc:  Service{Name: "ssh", Logging: "journald", JournalMatch: []string{"_COMM=sshd", "+", "_COMM=sshd-session"}, Enabled: true},
+		},
+		{
+			name:    "journald without source",
+			svc:     Service{Name: "ssh", Logging: "journald", Enabled: true},
+			wantErr: true,
+		},
+		{
+			name:    "identifier on file",
+			svc:     Service{Name: "ssh", Logging: "file", LogPath: "/var/log/auth.log", SyslogIdentifier: StringList{"sshd"}, Enabled: true},
+			wantErr: true,
+		},
+		{
+			name:    "bad identifier",
+			svc:     Service{Name: "ssh", Logging: "journald", SyslogIdentifier: StringList{"-f"}, Enabled: true},
+			wantErr: true,
+		},
+		{
+			name:    "bad match",
+			svc:     Service{Name: "ssh", Logging: "journald", JournalMatch: []string{"--output=cat"}, Enabled: true},
+			wantErr: true,
+		},
+		{
+			name: "pattern",
+			svc: Service{
+				Name: "postfix", Logging: "file", LogPath: "/var/log/mail.log", Enabled: true,
+				Pattern: `SASL LOGIN authentication failed.*\[(?P<ip>[0-9a-f.:]+)\]`,
+			},
+		},
+		{
+			name: "parser and pattern",
+			svc: Service{
+				Name: "app", Logging: "file", LogPath: "/x", Enabled: true,
+				Parser: "nginx", Pattern: `(?P<ip>\S+)`,
+			},
+			wantErr: true,
+		},
+		{
+			name:    "pattern without ip",
+			svc:     Service{Name: "app", Logging: "file", LogPath: "/x", Enabled: true, Pattern: `(?P<user>\w+) failed`},
+			wantErr: true,
+		},
+		{
+			name:    "pattern with unknown group",
+			svc:     Service{Name: "app", Logging: "file", LogPath: "/x", Enabled: true, Pattern: `(?P<ip>\S+) (?P<port>\d+)`},
+			wantErr: true,
+		},
+		{
+			name:    "invalid pattern",
+			svc:     Service{Name: "app", Logging: "file", LogPath: "/x", Enabled: true, Pattern: `(?P<ip>\S+`},
+			wantErr: true,
+		},
+		{
+			name:    "lower case match field",
+			svc:     Service{Name: "ssh", Logging: "journald", JournalMatch: []string{"_comm=sshd"}, Enabled: true},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.svc.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const watchMask = unix.IN_CREATE | unix.IN_CLOSE_WRITE | unix.IN_DELETE |
+	unix.IN_MOVED_TO | unix.IN_MOVED_FROM | unix.IN_DELETE_SELF | unix.IN_MOVE_SELF
+
+// WatchRules watches dir with inotify and sends on the returned channel once
+// a burst of changes to *.toml files has been quiet for debounce. The channel
+// is closed when ctx is done or the directory itself goes away.
+func WatchRules(ctx context.Context, dir string, debounce time.Duration) (<-chan struct{}, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init inotify: %w", err)
+	}
+	if _, err := unix.InotifyAddWatch(fd, dir, watchMask); err != nil {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+	// A non-blocking fd is registered with the runtime poller, so closing the
+	// file unblocks a pending Read.
+	f := os.NewFile(uintptr(fd), "inotify")
+
+	changed := make(chan struct{}, 1)
+	go readInotify(f, changed)
+
+	out := make(chan struct{}, 1)
+	go func() {
+		defer close(out)
+		defer f.Close()
+
+		timer := time.NewTimer(debounce)
+		timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-changed:
+				if !ok {
+					return
+				}
+				timer.Reset(debounce)
+			case <-timer.C:
+				select {
+				case out <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// readInotify signals changed for every event on a rule file and closes it
+// when the watch is removed or the file is closed.
+func readInotify(f *os.File, changed chan<- struct{}) {
+	defer close(changed)
+
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := f.Read(buf)
+		if err != nil {
+			return
+		}
+		notify, gone := false, false
+		for off := 0; off+unix.SizeofInotifyEvent <= n; {
+			ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			nameStart := off + unix.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[nameStart:nameStart+int(ev.Len)]), "\x00")
+			off = nameStart + int(ev.Len)
+
+			if ev.Mask&(unix.IN_DELETE_SELF|unix.IN_MOVE_SELF|unix.IN_IGNORED) != 0 {
+				gone = true
+				continue
+			}
+			if strings.HasSuffix(name, ".toml") {
+				notify = true
+			}
+		}
+		if notify {
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		}
+		if gone {
+			return
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func expectSignal(t *testing.T, ch <-chan struct{}, want bool) {
+	t.Helper()
+	select {
+	case _, ok := <-ch:
+		if !ok {
+			t.Fatal("watch channel closed")
+		}
+		if !want {
+			t.Fatal("unexpected change notification")
+		}
+	case <-time.After(500 * time.Millisecond):
+		if want {
+			t.Fatal("no change notification")
+		}
+	}
+}
+
+func TestWatchRules(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := WatchRules(ctx, dir, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("WatchRules() error = %v", err)
+	}
+
+	write := func(name string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("[[rule]]\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("burst is debounced", func(t *testing.T) {
+		for range 5 {
+			write("nginx.toml")
+		}
+		expectSignal(t, ch, true)
+		expectSignal(t, ch, false)
+	})
+
+	t.Run("non-toml files are ignored", func(t *testing.T) {
+		write(".nginx.toml.swp")
+		expectSignal(t, ch, false)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if err := os.Remove(filepath.Join(dir, "nginx.toml")); err != nil {
+			t.Fatal(err)
+		}
+		expectSignal(t, ch, true)
+	})
+
+	t.Run("rename into place", func(t *testing.T) {
+		tmp := filepath.Join(t.TempDir(), "ssh.toml")
+		if err := os.WriteFile(tmp, []byte("[[rule]]\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, filepath.Join(dir, "ssh.toml")); err != nil {
+			t.Fatal(err)
+		}
+		expectSignal(t, ch, true)
+	})
+
+	cancel()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Fatal("unexpected change notification after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("watch channel not closed after cancel")
+	}
+}
+
+func TestWatchRulesMissingDir(t *testing.T) {
+	_, err := WatchRules(context.Background(), filepath.Join(t.TempDir(), "missing"), time.Second)
+	if err == nil {
+		t.Fatal("WatchRules() expected error for missing directory")
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package config
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// WatchRules is only implemented on Linux, where it uses inotify.
+func WatchRules(ctx context.Context, dir string, debounce time.Duration) (<-chan struct{}, error) {
+	return nil, errors.New("watching rules is only supported on linux")
+}
@@ -0,0 +1,102 @@
+package judge
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// maxTrackedKeys bounds the number of (IP, rule) pairs kept in memory.
+// The least recently hit pair is evicted once the limit is reached.
+const maxTrackedKeys = 100000
+
+type hitKey struct {
+	ip   string
+	rule string
+}
+
+type hitWindow struct {
+	key  hitKey
+	hits []time.Time
+}
+
+// retryCounter is a sliding-window hit counter keyed by IP and rule. It keeps
+// at most limit timestamps per key, which is all that is needed to decide
+// whether max_retry was reached inside find_time.
+type retryCounter struct {
+	mu      sync.Mutex
+	maxKeys int
+	lru     *list.List
+	entries map[hitKey]*list.Element
+}
+
+func newRetryCounter(maxKeys int) *retryCounter {
+	return &retryCounter{
+		maxKeys: maxKeys,
+		lru:     list.New(),
+		entries: make(map[hitKey]*list.Element),
+	}
+}
+
+// Hit records a hit for ip and rule at t and returns the number of hits,
+// including this one, within window before t. Hits recorded with a later
+// time, as when logs are read out of order, are kept but not counted. At
+// most limit hits are kept.
+func (c *retryCounter) Hit(ip, rule string, t time.Time, window time.Duration, limit int) int {
+	if limit < 1 {
+		limit = 1
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := hitKey{ip: ip, rule: rule}
+	var w *hitWindow
+	if el, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(el)
+		w = el.Value.(*hitWindow)
+	} else {
+		if c.lru.Len() >= c.maxKeys {
+			c.evictOldest()
+		}
+		w = &hitWindow{key: key, hits: make([]time.Time, 0, limit)}
+		c.entries[key] = c.lru.PushFront(w)
+	}
+
+	cutoff := t.Add(-window)
+	kept := w.hits[:0]
+	for _, hit := range w.hits {
+		if !hit.Before(cutoff) {
+			kept = append(kept, hit)
+		}
+	}
+	kept = append(kept, t)
+	if len(kept) > limit {
+		kept = kept[len(kept)-limit:]
+	}
+	w.hits = kept
+
+	count := 0
+	for _, hit := range w.hits {
+		if !hit.After(t) {
+			count++
+		}
+	}
+	return count
+}
+
+// Len returns the number of tracked (IP, rule) pairs.
+func (c *retryCounter) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+func (c *retryCounter) evictOldest() {
+	el := c.lru.Back()
+	if el == nil {
+		return
+	}
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*hitWindow).key)
+}
@@ -0,0 +1,200 @@
+package judge
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/d3m0k1d/BanForge/internal/config"
+	"github.com/d3m0k1d/BanForge/internal/storage"
+	_ "modernc.org/sqlite"
+)
+
+func TestRetryCounterSlidingWindow(t *testing.T) {
+	c := newRetryCounter(10)
+	start := time.Now()
+
+	hits := []struct {
+		offset time.Duration
+		want   int
+	}{
+		{offset: 0, want: 1},
+		{offset: time.Minute, want: 2},
+		{offset: 2 * time.Minute, want: 3},
+		{offset: 11*time.Minute + 30*time.Second, want: 2},
+		{offset: 30 * time.Minute, want: 1},
+	}
+
+	for _, h := range hits {
+		got := c.Hit("192.0.2.10", "wp-login", start.Add(h.offset), 10*time.Minute, 5)
+		if got != h.want {
+			t.Errorf("Hit at +%v = %d, want %d", h.offset, got, h.want)
+		}
+	}
+}
+
+func TestRetryCounterIgnoresLaterHits(t *testing.T) {
+	c := newRetryCounter(10)
+	day := time.Date(2026, 10, 10, 12, 0, 0, 0, time.UTC)
+
+	// Rotated logs replayed newest first: three hits on the 10th, then
+	// one on the 9th.
+	for i := range 3 {
+		c.Hit("192.0.2.10", "wp-login", day.Add(time.Duration(i)*time.Minute), 10*time.Minute, 5)
+	}
+	if got := c.Hit("192.0.2.10", "wp-login", day.AddDate(0, 0, -1), 10*time.Minute, 5); got != 1 {
+		t.Errorf("Hit a day earlier = %d, want 1", got)
+	}
+	// The later hits still count once time catches up with them.
+	if got := c.Hit("192.0.2.10", "wp-login", day.Add(3*time.Minute), 10*time.Minute, 5); got != 4 {
+		t.Errorf("Hit after the later hits = %d, want 4", got)
+	}
+}
+
+func TestRetryCounterKeysAreIndependent(t *testing.T) {
+	c := newRetryCounter(10)
+	now := time.Now()
+
+	c.Hit("192.0.2.10", "wp-login", now, time.Hour, 5)
+	c.Hit("192.0.2.10", "wp-login", now, time.Hour, 5)
+
+	if got := c.Hit("192.0.2.10", "404-flood", now, time.Hour, 5); got != 1 {
+		t.Errorf("other rule count = %d, want 1", got)
+	}
+	if got := c.Hit("192.0.2.11", "wp-login", now, time.Hour, 5); got != 1 {
+		t.Errorf("other IP count = %d, want 1", got)
+	}
+}
+
+func TestRetryCounterLimitsHitsPerKey(t *testing.T) {
+	c := newRetryCounter(10)
+	now := time.Now()
+
+	var got int
+	for i := 0; i < 100; i++ {
+		got = c.Hit("192.0.2.10", "wp-login", now, time.Hour, 3)
+	}
+	if got != 3 {
+		t.Errorf("count = %d, want capped at 3", got)
+	}
+}
+
+func TestRetryCounterEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newRetryCounter(2)
+	now := time.Now()
+
+	c.Hit("192.0.2.1", "r", now, time.Hour, 5)
+	c.Hit("192.0.2.2", "r", now, time.Hour, 5)
+	c.Hit("192.0.2.1", "r", now, time.Hour, 5)
+	c.Hit("192.0.2.3", "r", now, time.Hour, 5)
+
+	if c.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", c.Len())
+	}
+	if got := c.Hit("192.0.2.1", "r", now, time.Hour, 5); got != 3 {
+		t.Errorf("recently used key count = %d, want 3", got)
+	}
+	if got := c.Hit("192.0.2.2", "r", now, time.Hour, 5); got != 1 {
+		t.Errorf("evicted key count = %d, want 1", got)
+	}
+}
+
+// staticHits is a HitReader over a fixed list of stored hits.
+type staticHits []storage.LogEntry
+
+func (h staticHits) RecentHits(since time.Time) ([]storage.LogEntry, error) {
+	var hits []storage.LogEntry
+	for _, hit := range h {
+		if hit.CreatedAt >= since.Format(time.RFC3339) {
+			hits = append(hits, hit)
+		}
+	}
+	return hits, nil
+}
+
+func TestJudgeWarmUp(t *testing.T) {
+	stored := time.Now().Add(-time.Minute).Format(time.RFC3339)
+	hits := staticHits{
+		{IP: "192.0.2.10", Rule: "wp-login", CreatedAt: stored},
+		{IP: "192.0.2.10", Rule: "wp-login", CreatedAt: stored},
+		{IP: "192.0.2.10", Rule: "removed-rule", CreatedAt: stored},
+		{IP: "192.0.2.11", Rule: "wp-login", CreatedAt: time.Now().Add(-time.Hour).Format(time.RFC3339)},
+	}
+
+	j := New(nil, nil, hits, nil, nil, nil)
+	if err := j.LoadRules([]config.Rule{
+		{Name: "wp-login", ServiceName: "nginx", MaxRetry: 5, FindTime: "10m"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.WarmUp(); err != nil {
+		t.Fatalf("WarmUp() error = %v", err)
+	}
+
+	if got := j.counter.Hit("192.0.2.10", "wp-login", time.Now(), 10*time.Minute, 5); got != 3 {
+		t.Errorf("count after warm-up = %d, want 3", got)
+	}
+	if j.counter.Len() != 1 {
+		t.Errorf("tracked keys = %d, want 1", j.counter.Len())
+	}
+}
+
+func BenchmarkRetryCheckMemory(b *testing.B) {
+	c := newRetryCounter(maxTrackedKeys)
+	now := time.Now()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ip := fmt.Sprintf("192.0.2.%d", i%250)
+		c.Hit(ip, "wp-login", now, 10*time.Minute, 50)
+	}
+}
+
+// BenchmarkRetryCheckSQLite is the COUNT(*) query the judge ran against
+// requests.db for every hit before it counted retries in memory, for
+// comparison with BenchmarkRetryCheckMemory.
+func BenchmarkRetryCheckSQLite(b *testing.B) {
+	db, err := sql.Open("sqlite", filepath.Join(b.TempDir(), "requests.db"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	if _, err := db.Exec(storage.CreateRequestsTable); err != nil {
+		b.Fatal(err)
+	}
+	now := time.Now()
+	tx, err := db.Begin()
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < 10000; i++ {
+		_, err := tx.Exec(
+			"INSERT INTO requests (service, ip, rule, created_at) VALUES (?, ?, ?, ?)",
+			"nginx", fmt.Sprintf("192.0.2.%d", i%250), "wp-login", now.Format(time.RFC3339),
+		)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		b.Fatal(err)
+	}
+	since := now.Add(-10 * time.Minute).Format(time.RFC3339)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ip := fmt.Sprintf("192.0.2.%d", i%250)
+		var count int
+		err := db.QueryRow(
+			"SELECT COUNT(*) FROM requests WHERE ip = ? AND rule = ? AND created_at >= ?",
+			ip, "wp-login", since,
+		).Scan(&count)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -1,8 +1,11 @@
 package judge
 
 import (
+	"errors"
 	"fmt"
-	"strings"
+	"net/netip"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/d3m0k1d/BanForge/internal/actions"
@@ -13,192 +16,606 @@ import (
 	"github.com/d3m0k1d/BanForge/internal/storage"
 )
 
+// HitReader reads the rule hits stored in the requests database.
+type HitReader interface {
+	RecentHits(since time.Time) ([]storage.LogEntry, error)
+}
+
 type Judge struct {
-	db_r           *storage.BanReader
-	db_w           *storage.BanWriter
-	db_rq          *storage.RequestReader
-	logger         *logger.Logger
-	Blocker        blocker.BlockerEngine
-	rulesByService map[string][]config.Rule
-	entryCh        chan *storage.LogEntry
-	resultCh       chan *storage.LogEntry
+	db_r     *storage.BanReader
+	db_w     *storage.BanWriter
+	db_rq    HitReader
+	logger   *logger.Logger
+	Blocker  blocker.BlockerEngine
+	state    atomic.Pointer[ruleSet]
+	updateMu sync.Mutex
+	counter  *retryCounter
+	entryCh  chan *storage.LogEntry
+	resultCh chan *storage.LogEntry
 }
 
 func New(
 	db_r *storage.BanReader,
 	db_w *storage.BanWriter,
-	db_rq *storage.RequestReader,
+	db_rq HitReader,
 	b blocker.BlockerEngine,
 	resultCh chan *storage.LogEntry,
 	entryCh chan *storage.LogEntry,
 ) *Judge {
-	return &Judge{
-		db_w:           db_w,
-		db_r:           db_r,
-		db_rq:          db_rq,
-		logger:         logger.New(false),
-		rulesByService: make(map[string][]config.Rule),
-		Blocker:        b,
-		entryCh:        entryCh,
-		resultCh:       resultCh,
+	j := &Judge{
+		db_w:     db_w,
+		db_r:     db_r,
+		db_rq:    db_rq,
+		logger:   logger.New(false),
+		counter:  newRetryCounter(maxTrackedKeys),
+		Blocker:  b,
+		entryCh:  entryCh,
+		resultCh: resultCh,
 	}
+	j.state.Store(&ruleSet{
+		byService: make(map[string][]config.Rule),
+		ruleMatch: config.RuleMatchFirst,
+	})
+	return j
 }
 
-func (j *Judge) LoadRules(rules []config.Rule) {
-	j.rulesByService = make(map[string][]config.Rule)
-	for _, rule := range rules {
-		j.rulesByService[rule.ServiceName] = append(
-			j.rulesByService[rule.ServiceName],
-			rule,
-		)
+// rules returns the rule set currently in force. It never changes once
+// returned; updates store a new one.
+func (j *Judge) rules() *ruleSet {
+	return j.state.Load()
+}
+
+// update applies change to a copy of the current rule set and swaps it in,
+// so Tribunal sees either the old or the new set, never a mix. On error the
+// current set stays in place.
+func (j *Judge) update(change func(rs *ruleSet) error) error {
+	j.updateMu.Lock()
+	defer j.updateMu.Unlock()
+	next := *j.state.Load()
+	if err := change(&next); err != nil {
+		return err
+	}
+	j.state.Store(&next)
+	return nil
+}
+
+func (j *Judge) LoadRules(rules []config.Rule) error {
+	err := j.update(func(rs *ruleSet) error {
+		return rs.setRules(rules)
+	})
+	if err != nil {
+		return err
 	}
 	j.logger.Info("Rules loaded and indexed by service")
+	return nil
+}
+
+// SetRuleMatch selects whether Tribunal stops at the first matching rule
+// (config.RuleMatchFirst) or evaluates all of them (config.RuleMatchAll).
+func (j *Judge) SetRuleMatch(mode string) error {
+	return j.update(func(rs *ruleSet) error {
+		return rs.setRuleMatch(mode)
+	})
+}
+
+// SetIgnoreIP sets the global ignore_ip list. Rules with their own ignore_ip
+// use that list instead.
+func (j *Judge) SetIgnoreIP(entries []string) error {
+	return j.update(func(rs *ruleSet) error {
+		return rs.setIgnoreIP(entries)
+	})
+}
+
+// SetSubnetBan enables escalation to a prefix ban once cfg.Threshold
+// addresses from it are banned. A disabled cfg turns escalation off.
+func (j *Judge) SetSubnetBan(cfg config.SubnetBan) error {
+	return j.update(func(rs *ruleSet) error {
+		return rs.setSubnetBan(cfg)
+	})
+}
+
+// SetMonitor puts every rule in monitor mode: bans are recorded, logged and
+// counted, but the firewall is never touched. The daemon sets it on startup.
+func (j *Judge) SetMonitor(on bool) {
+	_ = j.update(func(rs *ruleSet) error {
+		rs.monitorAll = on
+		return nil
+	})
+}
+
+// Reload replaces rules and every judge setting taken from cfg in a single
+// swap. If anything is invalid the running rule set is left untouched.
+func (j *Judge) Reload(cfg *config.Config, rules []config.Rule) error {
+	err := j.update(func(rs *ruleSet) error {
+		if err := rs.setRules(rules); err != nil {
+			return err
+		}
+		rs.setServices(cfg.Service)
+		if err := rs.setIgnoreIP(cfg.IgnoreIP); err != nil {
+			return err
+		}
+		if err := rs.setRuleMatch(cfg.RuleMatch); err != nil {
+			return err
+		}
+		rs.monitorActions = cfg.MonitorActions
+		return rs.setSubnetBan(cfg.SubnetBan)
+	})
+	if err != nil {
+		return err
+	}
+	j.logger.Info("Rules and judge settings reloaded", "rules", len(rules))
+	return nil
+}
+
+func subnetFor(ip string) (netip.Prefix, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	addr = addr.Unmap()
+	bits := ipv6SubnetBits
+	if addr.Is4() {
+		bits = ipv4SubnetBits
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	return prefix, true
+}
+
+// escalateSubnet bans the prefix around ip when enough of its addresses are
+// banned already. Prefixes overlapping any ignore_ip entry are never banned.
+func (j *Judge) escalateSubnet(rs *ruleSet, ip string, rule config.Rule) {
+	if rs.subnetBan == nil {
+		return
+	}
+	prefix, ok := subnetFor(ip)
+	if !ok {
+		return
+	}
+	target := prefix.String()
+
+	banned, err := j.db_r.IsBanned(target)
+	if err != nil {
+		j.logger.Error("Failed to check ban status", "ip", target, "error", err)
+		metrics.IncError()
+		return
+	}
+	if banned {
+		return
+	}
+	count, err := j.db_r.ActiveBansIn(prefix)
+	if err != nil {
+		j.logger.Error("Failed to count bans in subnet", "subnet", target, "error", err)
+		metrics.IncError()
+		return
+	}
+	if count < rs.subnetBan.threshold {
+		return
+	}
+	if rs.ignoreIP.Overlaps(target) || rs.ignoreByRule[rule.Name].Overlaps(target) {
+		j.logger.Warn("Subnet ban refused: subnet overlaps ignore_ip", "subnet", target, "rule", rule.Name)
+		metrics.IncBanRefused("judge")
+		return
+	}
+
+	err = j.db_w.AddBanFor(target, rs.subnetBan.banTime, rule.Name, storage.SourceJudge)
+	if err != nil {
+		j.logger.Error("Failed to add ban to database", "ip", target, "error", err)
+		return
+	}
+	if err := blocker.BanFor(j.Blocker, target, rs.subnetBan.banTime); err != nil {
+		j.logger.Error("Failed to ban subnet at firewall", "subnet", target, "error", err)
+		metrics.IncError()
+		return
+	}
+	j.logger.Info(
+		"Subnet banned",
+		"subnet", target,
+		"rule", rule.Name,
+		"banned_addresses", count,
+		"ban_time", rs.subnetBan.banTime,
+	)
+	metrics.IncBan(rule.ServiceName)
+}
+
+// WarmUp replays recent rule hits from the requests database into the
+// in-memory retry counter, so a restart does not reset find_time windows.
+// LoadRules must be called first.
+func (j *Judge) WarmUp() error {
+	windows := make(map[string]time.Duration)
+	limits := make(map[string]int)
+	var longest time.Duration
+	for _, rules := range j.rules().byService {
+		for _, rule := range rules {
+			findTime, err := rule.FindTimeDuration()
+			if err != nil {
+				continue
+			}
+			windows[rule.Name] = findTime
+			limits[rule.Name] = rule.MaxRetry
+			longest = max(longest, findTime)
+		}
+	}
+	if len(windows) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	hits, err := j.db_rq.RecentHits(now.Add(-longest))
+	if err != nil {
+		return err
+	}
+
+	restored := 0
+	for _, hit := range hits {
+		findTime, ok := windows[hit.Rule]
+		if !ok {
+			continue
+		}
+		createdAt, err := time.Parse(time.RFC3339, hit.CreatedAt)
+		if err != nil || createdAt.Before(now.Add(-findTime)) {
+			continue
+		}
+		j.counter.Hit(hit.IP, hit.Rule, createdAt, findTime, limits[hit.Rule])
+		restored++
+	}
+	j.logger.Info("Retry counter warmed up", "hits", restored, "keys", j.counter.Len())
+	return nil
 }
 
 func (j *Judge) Tribunal() {
 	j.logger.Info("Tribunal started")
 
 	for entry := range j.entryCh {
-		j.logger.Debug(
-			"Processing entry",
-			"ip",
-			entry.IP,
-			"service",
-			entry.Service,
-			"status",
-			entry.Status,
-		)
+		j.Hear(entry)
+	}
 
-		rules, serviceExists := j.rulesByService[entry.Service]
-		if !serviceExists {
-			j.logger.Debug("No rules for service", "service", entry.Service)
+	j.logger.Info("Tribunal stopped - entryCh closed")
+}
+
+// Hear judges a single log entry: it records a hit for every matching rule
+// and bans the address once a rule asks for it. Tribunal calls it for each
+// entry it receives; offline tools call it directly to judge in order.
+func (j *Judge) Hear(entry *storage.LogEntry) {
+	j.logger.Debug(
+		"Processing entry",
+		"ip",
+		entry.IP,
+		"service",
+		entry.Service,
+		"status",
+		entry.Status,
+	)
+
+	rs := j.rules()
+	rules, serviceExists := rs.byService[entry.Service]
+	if !serviceExists {
+		j.logger.Debug("No rules for service", "service", entry.Service)
+		return
+	}
+
+	ruleMatched := false
+	var candidates []config.Rule
+	for _, rule := range rules {
+		if !rs.matchRule(rule, entry) {
 			continue
 		}
+		ruleMatched = true
+		if j.judgeRule(rs, rule, entry) {
+			candidates = append(candidates, rule)
+		}
+		if rs.ruleMatch != config.RuleMatchAll {
+			break
+		}
+	}
 
-		ruleMatched := false
-		for _, rule := range rules {
-			methodMatch := rule.Method == "" || entry.Method == rule.Method
-			statusMatch := rule.Status == "" || entry.Status == rule.Status
-			pathMatch := matchPath(entry.Path, rule.Path)
-			if methodMatch && statusMatch && pathMatch {
-				ruleMatched = true
-				j.logger.Info("Rule matched", "rule", rule.Name, "ip", entry.IP)
-				j.resultCh <- entry
-				banned, err := j.db_r.IsBanned(entry.IP)
-				if err != nil {
-					j.logger.Error("Failed to check ban status", "ip", entry.IP, "error", err)
-					metrics.IncError()
-					break
-				}
-				if banned {
-					j.logger.Info("IP already banned", "ip", entry.IP)
-					metrics.IncLogParsed()
-					break
-				}
-				exceeded, err := j.db_rq.IsMaxRetryExceeded(entry.IP, rule.MaxRetry)
-				if err != nil {
-					j.logger.Error("Failed to check retry count", "ip", entry.IP, "error", err)
-					metrics.IncError()
-					break
-				}
-				if !exceeded {
-					j.logger.Info("Max retry not exceeded", "ip", entry.IP)
-					metrics.IncLogParsed()
-					break
-				}
-				err = j.db_w.AddBan(entry.IP, rule.BanTime, rule.Name)
-				if err != nil {
-					j.logger.Error(
-						"Failed to add ban to database",
-						"ip",
-						entry.IP,
-						"ban_time",
-						rule.BanTime,
-						"error",
-						err,
-					)
-					break
-				}
-
-				if err := j.Blocker.Ban(entry.IP); err != nil {
-					j.logger.Error("Failed to ban IP at firewall", "ip", entry.IP, "error", err)
-					metrics.IncError()
-					break
-				}
-
-				for _, action := range rule.Action {
-					executor := &actions.Executor{Action: action}
-					if err := executor.Execute(); err != nil {
-						j.logger.Error("Action execution failed",
-							"rule", rule.Name,
-							"action_type", action.Type,
-							"error", err)
-					}
-				}
-
-				j.logger.Info(
-					"IP banned successfully",
-					"ip",
-					entry.IP,
-					"rule",
-					rule.Name,
-					"ban_time",
-					rule.BanTime,
-				)
-				metrics.IncBan(rule.ServiceName)
-				break
-			}
+	if !ruleMatched {
+		j.logger.Debug("No rules matched", "ip", entry.IP, "service", entry.Service)
+		return
+	}
+	if len(candidates) > 0 {
+		j.sentence(rs, entry, candidates)
+	}
+}
+
+// Rule fields reported in RuleVerdict.Mismatches.
+const (
+	FieldMethod         = "method"
+	FieldStatus         = "status"
+	FieldPath           = "path"
+	FieldPathRegex      = "path_regex"
+	FieldUserAgentRegex = "user_agent_regex"
+)
+
+// RuleVerdict is how one rule judges a log entry.
+type RuleVerdict struct {
+	Rule config.Rule
+	// Mismatches lists the rule fields the entry does not satisfy. It is
+	// empty when the rule matches.
+	Mismatches []string
+	// Shadowed is set on a matching rule that Tribunal would not evaluate,
+	// because an earlier rule matched and rule_match is "first".
+	Shadowed bool
+	// Ignored is set when the entry's IP is in the ignore_ip list that
+	// applies to the rule, so the rule never bans it.
+	Ignored bool
+	// Monitor is set when the rule only records the bans it would make.
+	Monitor bool
+}
+
+// Explain judges entry against every rule of its service, in evaluation
+// order, without recording hits or banning. It returns nil if the service
+// has no rules.
+func (j *Judge) Explain(entry *storage.LogEntry) []RuleVerdict {
+	rs := j.rules()
+	rules := rs.byService[entry.Service]
+	if len(rules) == 0 {
+		return nil
+	}
+	verdicts := make([]RuleVerdict, 0, len(rules))
+	matched := false
+	for _, rule := range rules {
+		v := RuleVerdict{Rule: rule, Mismatches: rs.mismatches(rule, entry), Monitor: rs.monitors(rule)}
+		if len(v.Mismatches) == 0 {
+			v.Shadowed = matched && rs.ruleMatch != config.RuleMatchAll
+			v.Ignored = rs.isIgnored(entry.IP, rule.Name)
+			matched = true
 		}
+		verdicts = append(verdicts, v)
+	}
+	return verdicts
+}
+
+// judgeRule records a hit of entry on rule and reports whether the rule
+// asks for a ban: max_retry is reached and the address is not ignored.
+func (j *Judge) judgeRule(rs *ruleSet, rule config.Rule, entry *storage.LogEntry) bool {
+	j.logger.Info("Rule matched", "rule", rule.Name, "ip", entry.IP)
+	hit := *entry
+	hit.Rule = rule.Name
+	j.resultCh <- &hit
+
+	findTime, err := rule.FindTimeDuration()
+	if err != nil {
+		j.logger.Error("Invalid find_time", "rule", rule.Name, "error", err)
+		metrics.IncError()
+		return false
+	}
+	count := j.counter.Hit(entry.IP, rule.Name, entry.When(), findTime, rule.MaxRetry)
+	if rule.MaxRetry > 0 && count < rule.MaxRetry {
+		j.logger.Info(
+			"Max retry not exceeded",
+			"ip", entry.IP,
+			"rule", rule.Name,
+			"count", count,
+			"maxRetry", rule.MaxRetry,
+		)
+		metrics.IncLogParsed()
+		return false
+	}
+	if rs.isIgnored(entry.IP, rule.Name) {
+		j.logger.Warn("Ban refused: IP is in ignore_ip", "ip", entry.IP, "rule", rule.Name)
+		metrics.IncBanRefused("judge")
+		return false
+	}
+	return true
+}
 
-		if !ruleMatched {
-			j.logger.Debug("No rules matched", "ip", entry.IP, "service", entry.Service)
+// sentence bans entry.IP once for the candidate rule with the longest ban
+// time. Ties go to the candidate evaluated first. Candidates in monitor mode
+// only count when no enforcing rule asks for a ban.
+func (j *Judge) sentence(rs *ruleSet, entry *storage.LogEntry, candidates []config.Rule) {
+	var enforcing []config.Rule
+	for _, candidate := range candidates {
+		if !rs.monitors(candidate) {
+			enforcing = append(enforcing, candidate)
 		}
 	}
+	if len(enforcing) == 0 {
+		j.observe(rs, entry, candidates)
+		return
+	}
+	candidates = enforcing
 
-	j.logger.Info("Tribunal stopped - entryCh closed")
+	banned, err := j.db_r.IsBanned(entry.IP)
+	if err != nil {
+		j.logger.Error("Failed to check ban status", "ip", entry.IP, "error", err)
+		metrics.IncError()
+		return
+	}
+	if banned {
+		j.logger.Info("IP already banned", "ip", entry.IP)
+		metrics.IncLogParsed()
+		return
+	}
+	previousBans, err := j.db_r.BanCount(entry.IP)
+	if err != nil {
+		j.logger.Error("Failed to count previous bans", "ip", entry.IP, "error", err)
+		metrics.IncError()
+		return
+	}
+
+	rule, banTime, ok := j.harshest(candidates, previousBans)
+	if !ok {
+		return
+	}
+
+	err = j.db_w.AddBanFor(entry.IP, banTime, rule.Name, storage.SourceJudge)
+	if err != nil {
+		j.logger.Error(
+			"Failed to add ban to database",
+			"ip",
+			entry.IP,
+			"ban_time",
+			banTime,
+			"error",
+			err,
+		)
+		return
+	}
+
+	if err := blocker.BanFor(j.Blocker, entry.IP, banTime); err != nil {
+		j.logger.Error("Failed to ban IP at firewall", "ip", entry.IP, "error", err)
+		metrics.IncError()
+		return
+	}
+
+	j.runActions(rule)
+
+	j.logger.Info(
+		"IP banned successfully",
+		"ip",
+		entry.IP,
+		"rule",
+		rule.Name,
+		"ban_time",
+		banTime,
+		"previous_bans",
+		previousBans,
+		"candidates",
+		len(candidates),
+	)
+	metrics.IncBan(rule.ServiceName)
+	j.escalateSubnet(rs, entry.IP, rule)
 }
 
-func (j *Judge) UnbanChecker() {
-	tick := time.NewTicker(5 * time.Minute)
-	defer tick.Stop()
+// observe is sentence for rules in monitor mode. The ban is recorded in
+// monitor_bans and ban_history, so it can be reviewed and expires like a real
+// one, but the firewall is left alone and there is no subnet escalation.
+func (j *Judge) observe(rs *ruleSet, entry *storage.LogEntry, candidates []config.Rule) {
+	banned, err := j.db_r.IsBanned(entry.IP)
+	if err != nil {
+		j.logger.Error("Failed to check ban status", "ip", entry.IP, "error", err)
+		metrics.IncError()
+		return
+	}
+	monitored, err := j.db_r.IsMonitored(entry.IP)
+	if err != nil {
+		j.logger.Error("Failed to check monitor ban status", "ip", entry.IP, "error", err)
+		metrics.IncError()
+		return
+	}
+	if banned || monitored {
+		j.logger.Debug("IP already banned", "ip", entry.IP, "monitor", monitored)
+		metrics.IncLogParsed()
+		return
+	}
 
-	for range tick.C {
-		ips, err := j.db_w.RemoveExpiredBans()
+	previousBans, err := j.db_r.BanCount(entry.IP)
+	if err != nil {
+		j.logger.Error("Failed to count previous bans", "ip", entry.IP, "error", err)
+		metrics.IncError()
+		return
+	}
+	monitorBans, err := j.db_r.MonitorBanCount(entry.IP)
+	if err != nil {
+		j.logger.Error("Failed to count previous bans", "ip", entry.IP, "error", err)
+		metrics.IncError()
+		return
+	}
+	previousBans += monitorBans
+
+	rule, banTime, ok := j.harshest(candidates, previousBans)
+	if !ok {
+		return
+	}
+	if err := j.db_w.AddMonitorBan(entry.IP, banTime, rule.Name); err != nil {
+		j.logger.Error("Failed to record monitor ban", "ip", entry.IP, "ban_time", banTime, "error", err)
+		return
+	}
+	if rs.monitorActions {
+		j.runActions(rule)
+	}
+
+	j.logger.Info(
+		"IP would be banned (monitor mode)",
+		"ip",
+		entry.IP,
+		"rule",
+		rule.Name,
+		"ban_time",
+		banTime,
+		"previous_bans",
+		previousBans,
+		"candidates",
+		len(candidates),
+	)
+	metrics.IncMonitorBan(rule.ServiceName)
+}
+
+// harshest picks the candidate with the longest ban for an IP banned
+// previousBans times before. ok is false if no candidate has a valid ban time.
+func (j *Judge) harshest(candidates []config.Rule, previousBans int) (rule config.Rule, banTime time.Duration, ok bool) {
+	for _, candidate := range candidates {
+		d, err := candidate.BanDuration(previousBans)
 		if err != nil {
-			j.logger.Error(fmt.Sprintf("Failed to check expired bans: %v", err))
+			j.logger.Error("Invalid ban time", "rule", candidate.Name, "error", err)
 			metrics.IncError()
 			continue
 		}
+		if !ok || d > banTime {
+			rule, banTime, ok = candidate, d, true
+		}
+	}
+	return rule, banTime, ok
+}
 
-		for _, ip := range ips {
-			if err := j.Blocker.Unban(ip); err != nil {
-				j.logger.Error(fmt.Sprintf("Failed to unban IP at firewall: %v", err))
-				metrics.IncError()
-			} else {
-				metrics.IncUnban("judge")
-			}
+func (j *Judge) runActions(rule config.Rule) {
+	for _, action := range rule.Action {
+		executor := &actions.Executor{Action: action}
+		if err := executor.Execute(); err != nil {
+			j.logger.Error("Action execution failed",
+				"rule", rule.Name,
+				"action_type", action.Type,
+				"error", err)
 		}
 	}
 }
 
-func matchPath(path string, rulePath string) bool {
-	if rulePath == "" {
-		return true
+// UnbanChecker lifts expired bans every five minutes. With a backend that
+// expires bans itself the firewall has usually dropped them already, and
+// Unban has nothing left to do.
+func (j *Judge) UnbanChecker() {
+	tick := time.NewTicker(5 * time.Minute)
+	defer tick.Stop()
+
+	for range tick.C {
+		j.liftExpiredBans()
 	}
+}
 
-	if strings.HasPrefix(rulePath, "*") {
-		suffix := strings.TrimPrefix(rulePath, "*")
-		return strings.HasSuffix(path, suffix)
+// liftExpiredBans removes expired bans from the database and unbans them at
+// the firewall. In monitor mode only monitor bans expire: bans applied while
+// enforcing stay in the firewall and on record until enforcing resumes.
+func (j *Judge) liftExpiredBans() {
+	if j.rules().monitorAll {
+		if err := j.db_w.RemoveExpiredMonitorBans(); err != nil {
+			j.logger.Error(fmt.Sprintf("Failed to check expired monitor bans: %v", err))
+			metrics.IncError()
+		}
+		return
 	}
 
-	if strings.HasPrefix(rulePath, "/*") {
-		suffix := strings.TrimPrefix(rulePath, "/*")
-		return strings.HasSuffix(path, suffix)
+	ips, err := j.db_w.RemoveExpiredBans()
+	if err != nil {
+		j.logger.Error(fmt.Sprintf("Failed to check expired bans: %v", err))
+		metrics.IncError()
+		return
 	}
 
-	if strings.HasSuffix(rulePath, "*") {
-		prefix := strings.TrimSuffix(rulePath, "*")
-		return strings.HasPrefix(path, prefix)
+	for _, ip := range ips {
+		err := j.Blocker.Unban(ip)
+		switch {
+		case errors.Is(err, blocker.ErrCovered):
+			// Its own ban is lifted; the wider ban expires by itself.
+			j.logger.Info("Expired ban lifted", "ip", ip, "note", err.Error())
+			metrics.IncUnban("judge")
+		case err != nil:
+			j.logger.Error(fmt.Sprintf("Failed to unban IP at firewall: %v", err))
+			metrics.IncError()
+		default:
+			metrics.IncUnban("judge")
+		}
 	}
-	return path == rulePath
 }
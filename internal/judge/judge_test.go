@@ -1,8 +1,14 @@
 package judge
 
 import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/d3m0k1d/BanForge/internal/blocker"
 	"github.com/d3m0k1d/BanForge/internal/config"
 	"github.com/d3m0k1d/BanForge/internal/storage"
 )
@@ -17,21 +23,21 @@ func TestJudgeLogic(t *testing.T) {
 	}{
 		{
 			name:      "Empty rule",
-			inputRule: config.Rule{Name: "", ServiceName: "", Path: "", Status: "", Method: ""},
+			inputRule: config.Rule{Name: "", ServiceName: "", Path: ""},
 			inputLog:  storage.LogEntry{ID: 0, Service: "nginx", IP: "127.0.0.1", Path: "/api", Status: "200", Method: "GET", CreatedAt: ""},
 			wantErr:   true,
 			wantMatch: false,
 		},
 		{
 			name:      "Matching rule",
-			inputRule: config.Rule{Name: "test", ServiceName: "nginx", Path: "/api", Status: "200", Method: "GET"},
+			inputRule: config.Rule{Name: "test", ServiceName: "nginx", Path: "/api", Status: config.StringList{"200"}, Method: config.StringList{"GET"}},
 			inputLog:  storage.LogEntry{ID: 1, Service: "nginx", IP: "127.0.0.1", Path: "/api", Status: "200", Method: "GET", CreatedAt: ""},
 			wantErr:   false,
 			wantMatch: true,
 		},
 		{
 			name:      "Non-matching status",
-			inputRule: config.Rule{Name: "test", ServiceName: "nginx", Path: "/api", Status: "404", Method: "GET"},
+			inputRule: config.Rule{Name: "test", ServiceName: "nginx", Path: "/api", Status: config.StringList{"404"}, Method: config.StringList{"GET"}},
 			inputLog:  storage.LogEntry{ID: 2, Service: "nginx", IP: "127.0.0.1", Path: "/api", Status: "200", Method: "GET", CreatedAt: ""},
 			wantErr:   false,
 			wantMatch: false,
@@ -47,8 +53,8 @@ func TestJudgeLogic(t *testing.T) {
 				return
 			}
 
-			result := (tt.inputRule.Method == "" || tt.inputLog.Method == tt.inputRule.Method) &&
-				(tt.inputRule.Status == "" || tt.inputLog.Status == tt.inputRule.Status) &&
+			result := tt.inputRule.MatchMethod(tt.inputLog.Method) &&
+				tt.inputRule.MatchStatus(tt.inputLog.Status) &&
 				(tt.inputRule.Path == "" || tt.inputLog.Path == tt.inputRule.Path) &&
 				(tt.inputRule.ServiceName == "" || tt.inputLog.Service == tt.inputRule.ServiceName)
 
@@ -58,3 +64,650 @@ func TestJudgeLogic(t *testing.T) {
 		})
 	}
 }
+
+func TestJudgeIgnoreIP(t *testing.T) {
+	j := New(nil, nil, nil, nil, nil, nil)
+	if err := j.SetIgnoreIP([]string{"127.0.0.1/8", "203.0.113.7"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.LoadRules([]config.Rule{
+		{Name: "global", ServiceName: "nginx"},
+		{Name: "override", ServiceName: "nginx", IgnoreIP: []string{"198.51.100.0/24"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ip   string
+		rule string
+		want bool
+	}{
+		{ip: "127.0.0.53", rule: "global", want: true},
+		{ip: "203.0.113.7", rule: "global", want: true},
+		{ip: "203.0.113.8", rule: "global", want: false},
+		{ip: "198.51.100.20", rule: "global", want: false},
+		{ip: "198.51.100.20", rule: "override", want: true},
+		{ip: "127.0.0.1", rule: "override", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip+"/"+tt.rule, func(t *testing.T) {
+			if got := j.rules().isIgnored(tt.ip, tt.rule); got != tt.want {
+				t.Errorf("isIgnored(%q, %q) = %v, want %v", tt.ip, tt.rule, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJudgeLoadRulesRejectsInvalidIgnoreIP(t *testing.T) {
+	j := New(nil, nil, nil, nil, nil, nil)
+	err := j.LoadRules([]config.Rule{
+		{Name: "bad", ServiceName: "nginx", IgnoreIP: []string{"10.0.0.0/33"}},
+	})
+	if err == nil {
+		t.Fatal("LoadRules() expected error for invalid ignore_ip")
+	}
+}
+
+type recordingBlocker struct {
+	banned   []string
+	unbanned []string
+}
+
+func (b *recordingBlocker) Ban(ip string) error {
+	b.banned = append(b.banned, ip)
+	return nil
+}
+
+func (b *recordingBlocker) Unban(ip string) error {
+	b.unbanned = append(b.unbanned, ip)
+	return nil
+}
+
+func (b *recordingBlocker) Setup(config string) error                 { return nil }
+func (b *recordingBlocker) PortOpen(port int, protocol string) error  { return nil }
+func (b *recordingBlocker) PortClose(port int, protocol string) error { return nil }
+
+// expiringBlocker is a recordingBlocker whose bans expire by themselves.
+type expiringBlocker struct {
+	recordingBlocker
+	timed []blocker.TimedBan
+}
+
+func (b *expiringBlocker) BanFor(bans ...blocker.TimedBan) error {
+	b.timed = append(b.timed, bans...)
+	return nil
+}
+
+func TestJudgeBanTimeReachesExpiringBlocker(t *testing.T) {
+	r, w := newJudgeTestBanDB(t)
+	b := &expiringBlocker{}
+	entryCh := make(chan *storage.LogEntry, 1)
+	resultCh := make(chan *storage.LogEntry, 1)
+	j := New(r, w, nil, b, resultCh, entryCh)
+	if err := j.LoadRules([]config.Rule{{Name: "scan", ServiceName: "nginx", Status: config.StringList{"404"}, BanTime: "2h"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	entryCh <- &storage.LogEntry{Service: "nginx", IP: "203.0.113.9", Path: "/", Status: "404", Method: "GET"}
+	close(entryCh)
+	j.Tribunal()
+
+	want := []blocker.TimedBan{{Target: "203.0.113.9", Duration: 2 * time.Hour}}
+	if !slices.Equal(b.timed, want) {
+		t.Errorf("BanFor() got %+v, want %+v", b.timed, want)
+	}
+	if len(b.banned) != 0 {
+		t.Errorf("Ban() got %v, want BanFor only", b.banned)
+	}
+}
+
+func TestJudgeEscalateSubnet(t *testing.T) {
+	r, w := newJudgeTestBanDB(t)
+	b := &recordingBlocker{}
+	j := New(r, w, nil, b, nil, nil)
+	if err := j.SetIgnoreIP([]string{"198.51.100.1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.SetSubnetBan(config.SubnetBan{Enabled: true, Threshold: 3, BanTime: "1h"}); err != nil {
+		t.Fatal(err)
+	}
+	rule := config.Rule{Name: "scan", ServiceName: "nginx"}
+
+	for _, ip := range []string{
+		"203.0.113.1", "203.0.113.2", "203.0.113.3",
+		"198.51.100.2", "198.51.100.3", "198.51.100.4",
+		"2001:db8::1", "2001:db8::2",
+	} {
+		if err := w.AddBanFor(ip, time.Hour, rule.Name, storage.SourceJudge); err != nil {
+			t.Fatal(err)
+		}
+		j.escalateSubnet(j.rules(), ip, rule)
+	}
+
+	if want := []string{"203.0.113.0/24"}; !slices.Equal(b.banned, want) {
+		t.Fatalf("banned = %v, want %v", b.banned, want)
+	}
+	banned, err := r.IsBanned("203.0.113.200")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !banned {
+		t.Error("address inside the banned subnet is not reported as banned")
+	}
+
+	// A second trigger must not ban the same subnet again.
+	j.escalateSubnet(j.rules(), "203.0.113.3", rule)
+	if len(b.banned) != 1 {
+		t.Errorf("subnet banned twice: %v", b.banned)
+	}
+}
+
+func TestJudgeMatchRuleRegex(t *testing.T) {
+	j := New(nil, nil, nil, nil, nil, nil)
+	rules := []config.Rule{
+		{Name: "probe", ServiceName: "nginx", PathRegex: `^/(wp-admin|xmlrpc\.php|\.env)`},
+		{Name: "scanner", ServiceName: "nginx", Status: config.StringList{"404"}, UserAgentRegex: `(?i)(zgrab|masscan|sqlmap)`},
+	}
+	if err := j.LoadRules(rules); err != nil {
+		t.Fatalf("LoadRules() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		rule  int
+		entry storage.LogEntry
+		want  bool
+	}{
+		{name: "path regex match", rule: 0, entry: storage.LogEntry{Path: "/xmlrpc.php"}, want: true},
+		{name: "path regex anchored", rule: 0, entry: storage.LogEntry{Path: "/blog/wp-admin"}, want: false},
+		{name: "path regex escaped dot", rule: 0, entry: storage.LogEntry{Path: "/xenv"}, want: false},
+		{
+			name:  "user agent match",
+			rule:  1,
+			entry: storage.LogEntry{Status: "404", UserAgent: "Mozilla/5.0 zgrab/0.x"},
+			want:  true,
+		},
+		{
+			name:  "user agent case insensitive",
+			rule:  1,
+			entry: storage.LogEntry{Status: "404", UserAgent: "SQLMap/1.7"},
+			want:  true,
+		},
+		{
+			name:  "user agent needs other fields",
+			rule:  1,
+			entry: storage.LogEntry{Status: "200", UserAgent: "zgrab"},
+			want:  false,
+		},
+		{name: "missing user agent", rule: 1, entry: storage.LogEntry{Status: "404"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := j.rules().matchRule(rules[tt.rule], &tt.entry); got != tt.want {
+				t.Errorf("matchRule() = %v, want %v", got, tt.want)
+			}
+			if got := j.rules().mismatches(rules[tt.rule], &tt.entry); (len(got) == 0) != tt.want {
+				t.Errorf("mismatches() = %v, want match %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJudgeLoadRulesRejectsInvalidRegex(t *testing.T) {
+	tests := []struct {
+		name string
+		rule config.Rule
+	}{
+		{name: "path_regex", rule: config.Rule{Name: "bad", ServiceName: "nginx", PathRegex: `/(wp-admin`}},
+		{name: "user_agent_regex", rule: config.Rule{Name: "bad", ServiceName: "nginx", UserAgentRegex: `[`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := New(nil, nil, nil, nil, nil, nil)
+			err := j.LoadRules([]config.Rule{tt.rule})
+			if err == nil || !strings.Contains(err.Error(), tt.name) {
+				t.Fatalf("LoadRules() error = %v, want %s error", err, tt.name)
+			}
+		})
+	}
+}
+
+func newJudgeTestBanDB(t *testing.T) (*storage.BanReader, *storage.BanWriter) {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "bans.db")
+	w, err := storage.NewBanWriterWithDBPath(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	if err := w.CreateTable(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := storage.NewBanReaderWithDBPath(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = r.Close() })
+	return r, w
+}
+
+func TestJudgeTribunalRuleMatch(t *testing.T) {
+	rules := []config.Rule{
+		{Name: "short", ServiceName: "nginx", Status: config.StringList{"404"}, BanTime: "1h"},
+		{Name: "long", ServiceName: "nginx", Status: config.StringList{"4xx"}, BanTime: "1d"},
+		{Name: "under-threshold", ServiceName: "nginx", Status: config.StringList{"404"}, BanTime: "1y", MaxRetry: 5},
+		{Name: "priority", ServiceName: "nginx", Status: config.StringList{"404"}, BanTime: "10m", Priority: 5},
+		{Name: "other", ServiceName: "nginx", Status: config.StringList{"500"}, BanTime: "1y"},
+	}
+
+	tests := []struct {
+		mode     string
+		wantRule string
+		wantHits int
+	}{
+		{mode: config.RuleMatchFirst, wantRule: "priority", wantHits: 1},
+		{mode: config.RuleMatchAll, wantRule: "long", wantHits: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			r, w := newJudgeTestBanDB(t)
+			b := &recordingBlocker{}
+			entryCh := make(chan *storage.LogEntry, 1)
+			resultCh := make(chan *storage.LogEntry, 10)
+			j := New(r, w, nil, b, resultCh, entryCh)
+			if err := j.LoadRules(rules); err != nil {
+				t.Fatal(err)
+			}
+			if err := j.SetRuleMatch(tt.mode); err != nil {
+				t.Fatal(err)
+			}
+
+			entryCh <- &storage.LogEntry{Service: "nginx", IP: "203.0.113.9", Path: "/", Status: "404", Method: "GET"}
+			close(entryCh)
+			j.Tribunal()
+
+			if len(resultCh) != tt.wantHits {
+				t.Errorf("recorded hits = %d, want %d", len(resultCh), tt.wantHits)
+			}
+			if !slices.Equal(b.banned, []string{"203.0.113.9"}) {
+				t.Fatalf("banned = %v, want a single ban", b.banned)
+			}
+			events, err := r.History(storage.HistoryFilter{IP: "203.0.113.9"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != 1 || events[0].Rule != tt.wantRule {
+				t.Fatalf("history = %+v, want one ban by %q", events, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestJudgeSetRuleMatchRejectsUnknownMode(t *testing.T) {
+	j := New(nil, nil, nil, nil, nil, nil)
+	if err := j.SetRuleMatch("best"); err == nil {
+		t.Fatal("SetRuleMatch() expected error for unknown mode")
+	}
+}
+
+func TestJudgeReload(t *testing.T) {
+	j := New(nil, nil, nil, nil, nil, nil)
+	cfg := &config.Config{RuleMatch: config.RuleMatchFirst, IgnoreIP: []string{"127.0.0.1"}}
+	if err := j.Reload(cfg, []config.Rule{{Name: "old", ServiceName: "nginx"}}); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		cfg   config.Config
+		rules []config.Rule
+	}{
+		{
+			name:  "invalid rule",
+			cfg:   config.Config{RuleMatch: config.RuleMatchAll},
+			rules: []config.Rule{{Name: "new", ServiceName: "ssh", PathRegex: "("}},
+		},
+		{
+			name:  "invalid ignore_ip",
+			cfg:   config.Config{RuleMatch: config.RuleMatchAll, IgnoreIP: []string{"bogus"}},
+			rules: []config.Rule{{Name: "new", ServiceName: "ssh"}},
+		},
+		{
+			name:  "invalid subnet_ban",
+			cfg:   config.Config{RuleMatch: config.RuleMatchAll, SubnetBan: config.SubnetBan{Enabled: true}},
+			rules: []config.Rule{{Name: "new", ServiceName: "ssh"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := j.Reload(&tt.cfg, tt.rules); err == nil {
+				t.Fatal("Reload() expected error")
+			}
+			rs := j.rules()
+			if _, ok := rs.byService["nginx"]; !ok || len(rs.byService) != 1 {
+				t.Errorf("rules changed after failed reload: %v", rs.byService)
+			}
+			if rs.ruleMatch != config.RuleMatchFirst || !rs.isIgnored("127.0.0.1", "old") {
+				t.Error("settings changed after failed reload")
+			}
+		})
+	}
+
+	next := &config.Config{RuleMatch: config.RuleMatchAll}
+	if err := j.Reload(next, []config.Rule{{Name: "new", ServiceName: "ssh"}}); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	rs := j.rules()
+	if _, ok := rs.byService["ssh"]; !ok || len(rs.byService) != 1 {
+		t.Errorf("rules not replaced: %v", rs.byService)
+	}
+	if rs.ruleMatch != config.RuleMatchAll || rs.isIgnored("127.0.0.1", "new") {
+		t.Error("settings not replaced")
+	}
+}
+
+func TestJudgeReloadWhileJudging(t *testing.T) {
+	r, w := newJudgeTestBanDB(t)
+	entryCh := make(chan *storage.LogEntry)
+	resultCh := make(chan *storage.LogEntry, 1000)
+	j := New(r, w, nil, &recordingBlocker{}, resultCh, entryCh)
+	cfg := &config.Config{RuleMatch: config.RuleMatchFirst}
+	rules := []config.Rule{{Name: "scan", ServiceName: "nginx", BanTime: "1h", MaxRetry: 1000}}
+	if err := j.Reload(cfg, rules); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		j.Tribunal()
+		close(done)
+	}()
+	for i := 0; i < 200; i++ {
+		entryCh <- &storage.LogEntry{Service: "nginx", IP: "198.51.100.1", Path: "/"}
+		if i%10 == 0 {
+			if err := j.Reload(cfg, rules); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	close(entryCh)
+	<-done
+	if len(resultCh) != 200 {
+		t.Errorf("recorded hits = %d, want 200", len(resultCh))
+	}
+}
+
+func TestJudgeTribunalUsesLogTime(t *testing.T) {
+	rules := []config.Rule{
+		{Name: "wp-login", ServiceName: "nginx", Path: "/wp-login.php", MaxRetry: 3, FindTime: "10m", BanTime: "1h"},
+	}
+	start := time.Now().Add(-2 * time.Hour)
+
+	tests := []struct {
+		name    string
+		spacing time.Duration
+		want    []string
+	}{
+		// A delayed batch: all lines arrive now, but were logged far apart.
+		{name: "spread beyond find_time", spacing: 6 * time.Minute},
+		{name: "within find_time", spacing: time.Minute, want: []string{"203.0.113.9"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, w := newJudgeTestBanDB(t)
+			b := &recordingBlocker{}
+			entryCh := make(chan *storage.LogEntry, 3)
+			resultCh := make(chan *storage.LogEntry, 3)
+			j := New(r, w, nil, b, resultCh, entryCh)
+			if err := j.LoadRules(rules); err != nil {
+				t.Fatal(err)
+			}
+
+			for i := range 3 {
+				entryCh <- &storage.LogEntry{
+					Service: "nginx",
+					IP:      "203.0.113.9",
+					Path:    "/wp-login.php",
+					Status:  "200",
+					Method:  "POST",
+					Time:    start.Add(time.Duration(i) * tt.spacing),
+				}
+			}
+			close(entryCh)
+			j.Tribunal()
+
+			if !slices.Equal(b.banned, tt.want) {
+				t.Errorf("banned = %v, want %v", b.banned, tt.want)
+			}
+		})
+	}
+}
+
+func TestJudgeExplain(t *testing.T) {
+	rules := []config.Rule{
+		{Name: "wp-login", ServiceName: "nginx", Path: "/wp-login.php", Method: config.StringList{"POST"}, Priority: 10},
+		{Name: "not-found", ServiceName: "nginx", Status: config.StringList{"404"}},
+		{Name: "any-post", ServiceName: "nginx", Method: config.StringList{"POST"}, IgnoreIP: []string{"203.0.113.0/24"}},
+		{Name: "scanner", ServiceName: "nginx", Path: "/.env", UserAgentRegex: `zgrab`},
+		{Name: "ssh", ServiceName: "ssh", Status: config.StringList{"Failed"}},
+	}
+	entry := &storage.LogEntry{Service: "nginx", IP: "203.0.113.9", Path: "/wp-login.php", Status: "200", Method: "POST", UserAgent: "curl"}
+
+	type verdict struct {
+		rule       string
+		mismatches []string
+		shadowed   bool
+		ignored    bool
+	}
+	tests := []struct {
+		mode string
+		want []verdict
+	}{
+		{
+			mode: config.RuleMatchFirst,
+			want: []verdict{
+				{rule: "wp-login"},
+				{rule: "not-found", mismatches: []string{FieldStatus}},
+				{rule: "any-post", shadowed: true, ignored: true},
+				{rule: "scanner", mismatches: []string{FieldPath, FieldUserAgentRegex}},
+			},
+		},
+		{
+			mode: config.RuleMatchAll,
+			want: []verdict{
+				{rule: "wp-login"},
+				{rule: "not-found", mismatches: []string{FieldStatus}},
+				{rule: "any-post", ignored: true},
+				{rule: "scanner", mismatches: []string{FieldPath, FieldUserAgentRegex}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			resultCh := make(chan *storage.LogEntry, 1)
+			j := New(nil, nil, nil, nil, resultCh, nil)
+			if err := j.LoadRules(rules); err != nil {
+				t.Fatal(err)
+			}
+			if err := j.SetRuleMatch(tt.mode); err != nil {
+				t.Fatal(err)
+			}
+
+			got := j.Explain(entry)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Explain() returned %d verdicts, want %d", len(got), len(tt.want))
+			}
+			for i, w := range tt.want {
+				g := got[i]
+				if g.Rule.Name != w.rule || !slices.Equal(g.Mismatches, w.mismatches) ||
+					g.Shadowed != w.shadowed || g.Ignored != w.ignored {
+					t.Errorf("verdict %d = %s %v shadowed=%v ignored=%v, want %+v",
+						i, g.Rule.Name, g.Mismatches, g.Shadowed, g.Ignored, w)
+				}
+			}
+			if len(resultCh) != 0 {
+				t.Error("Explain() recorded a hit")
+			}
+		})
+	}
+
+	j := New(nil, nil, nil, nil, nil, nil)
+	if got := j.Explain(&storage.LogEntry{Service: "apache"}); got != nil {
+		t.Errorf("Explain() for a service without rules = %v, want nil", got)
+	}
+}
+
+func TestJudgeMonitorMode(t *testing.T) {
+	enforce := config.Rule{Name: "enforce", ServiceName: "nginx", Status: config.StringList{"404"}, BanTime: "1h"}
+	trial := config.Rule{Name: "trial", ServiceName: "nginx", Status: config.StringList{"4xx"}, BanTime: "1d", Mode: config.ModeMonitor}
+
+	tests := []struct {
+		name       string
+		rules      []config.Rule
+		monitorAll bool
+		wantBanned []string
+		wantRule   string
+		wantSource string
+	}{
+		{
+			name:       "daemon in monitor mode",
+			rules:      []config.Rule{enforce},
+			monitorAll: true,
+			wantRule:   "enforce",
+			wantSource: storage.SourceMonitor,
+		},
+		{
+			name:       "rule in monitor mode",
+			rules:      []config.Rule{trial},
+			wantRule:   "trial",
+			wantSource: storage.SourceMonitor,
+		},
+		{
+			name:       "enforcing rule wins over a longer trial",
+			rules:      []config.Rule{trial, enforce},
+			wantBanned: []string{"203.0.113.9"},
+			wantRule:   "enforce",
+			wantSource: storage.SourceJudge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, w := newJudgeTestBanDB(t)
+			b := &recordingBlocker{}
+			entryCh := make(chan *storage.LogEntry, 2)
+			resultCh := make(chan *storage.LogEntry, 10)
+			j := New(r, w, nil, b, resultCh, entryCh)
+			if err := j.LoadRules(tt.rules); err != nil {
+				t.Fatal(err)
+			}
+			if err := j.SetRuleMatch(config.RuleMatchAll); err != nil {
+				t.Fatal(err)
+			}
+			j.SetMonitor(tt.monitorAll)
+
+			// The second line must not record a second ban.
+			for range 2 {
+				entryCh <- &storage.LogEntry{Service: "nginx", IP: "203.0.113.9", Path: "/", Status: "404", Method: "GET"}
+			}
+			close(entryCh)
+			j.Tribunal()
+
+			if !slices.Equal(b.banned, tt.wantBanned) {
+				t.Errorf("banned at firewall = %v, want %v", b.banned, tt.wantBanned)
+			}
+			events, err := r.History(storage.HistoryFilter{IP: "203.0.113.9"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != 1 || events[0].Rule != tt.wantRule || events[0].Source != tt.wantSource {
+				t.Fatalf("history = %+v, want one ban by %q from %s", events, tt.wantRule, tt.wantSource)
+			}
+			banned, err := r.IsBanned("203.0.113.9")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if banned != (tt.wantSource == storage.SourceJudge) {
+				t.Errorf("IsBanned() = %v", banned)
+			}
+		})
+	}
+}
+
+func TestJudgeSshRuleWithoutStatusCountsFailuresOnly(t *testing.T) {
+	// One login attempt for an unknown user, as sshd logs it.
+	attempt := []string{"InvalidUser", "Failed", "MaxAuthTries", "AuthClosed", "PreauthDisconnect"}
+
+	tests := []struct {
+		name    string
+		service config.Service
+		status  config.StringList
+		want    int
+	}{
+		{name: "ssh parser", service: config.Service{Name: "sshd", Parser: "ssh"}, want: 1},
+		{name: "service named ssh", service: config.Service{Name: "ssh"}, want: 1},
+		{name: "explicit statuses", service: config.Service{Name: "sshd", Parser: "ssh"}, status: config.StringList{"Failed", "InvalidUser"}, want: 2},
+		{name: "other parser", service: config.Service{Name: "vpn", Parser: "openvpn"}, want: len(attempt)},
+		{name: "own pattern", service: config.Service{Name: "ssh", Pattern: `(?P<ip>\S+)`}, want: len(attempt)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resultCh := make(chan *storage.LogEntry, len(attempt))
+			j := New(nil, nil, nil, nil, resultCh, nil)
+			cfg := &config.Config{RuleMatch: config.RuleMatchFirst, Service: []config.Service{tt.service}}
+			rules := []config.Rule{{Name: "brute", ServiceName: tt.service.Name, Status: tt.status, MaxRetry: 10, FindTime: "10m"}}
+			if err := j.Reload(cfg, rules); err != nil {
+				t.Fatalf("Reload() error = %v", err)
+			}
+
+			for _, status := range attempt {
+				j.Hear(&storage.LogEntry{Service: tt.service.Name, IP: "203.0.113.7", Path: "admin", Status: status})
+			}
+			if got := len(resultCh); got != tt.want {
+				t.Errorf("hits = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJudgeLiftExpiredBans(t *testing.T) {
+	for _, monitor := range []bool{false, true} {
+		t.Run(fmt.Sprintf("monitor=%v", monitor), func(t *testing.T) {
+			r, w := newJudgeTestBanDB(t)
+			b := &recordingBlocker{}
+			j := New(r, w, nil, b, nil, nil)
+			j.SetMonitor(monitor)
+			if err := w.AddBan("203.0.113.5", "-1h", "wp-login"); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.AddMonitorBan("203.0.113.6", -time.Hour, "wp-login"); err != nil {
+				t.Fatal(err)
+			}
+
+			j.liftExpiredBans()
+
+			if monitored, err := r.IsMonitored("203.0.113.6"); err != nil || monitored {
+				t.Errorf("IsMonitored() = %v, %v, want expired monitor ban removed", monitored, err)
+			}
+			// In monitor mode a ban from enforcing stays on record, as it
+			// stays in the firewall.
+			if banned, err := r.IsBanned("203.0.113.5"); err != nil || banned != monitor {
+				t.Errorf("IsBanned() = %v, %v, want %v", banned, err, monitor)
+			}
+			var want []string
+			if !monitor {
+				want = []string{"203.0.113.5"}
+			}
+			if !slices.Equal(b.unbanned, want) {
+				t.Errorf("unbanned = %v, want %v", b.unbanned, want)
+			}
+		})
+	}
+}
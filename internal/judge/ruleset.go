@@ -0,0 +1,229 @@
+package judge
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/d3m0k1d/BanForge/internal/config"
+	"github.com/d3m0k1d/BanForge/internal/storage"
+)
+
+// ruleSet is everything Tribunal needs to judge a log line. A ruleSet is
+// never modified after it has been stored in Judge.state.
+type ruleSet struct {
+	byService    map[string][]config.Rule
+	ignoreIP     *config.IPMatcher
+	ignoreByRule map[string]*config.IPMatcher
+	regexByRule  map[string]ruleRegex
+	ruleMatch    string
+	subnetBan    *subnetBan
+	// defaultStatus holds the statuses a rule without status matches, by
+	// service, for the configured services.
+	defaultStatus map[string]config.StringList
+	// monitorAll puts every rule in monitor mode, as the daemon does with
+	// --dry-run or mode = "monitor".
+	monitorAll     bool
+	monitorActions bool
+}
+
+// ruleRegex holds the compiled path_regex and user_agent_regex of a rule.
+// Unset patterns are nil.
+type ruleRegex struct {
+	path      *regexp.Regexp
+	userAgent *regexp.Regexp
+}
+
+// Subnet escalation bans the /24 or /64 around an offender.
+const (
+	ipv4SubnetBits = 24
+	ipv6SubnetBits = 64
+)
+
+type subnetBan struct {
+	threshold int
+	banTime   time.Duration
+}
+
+// monitors reports whether rule only records the bans it would make.
+func (rs *ruleSet) monitors(rule config.Rule) bool {
+	return rs.monitorAll || rule.Mode == config.ModeMonitor
+}
+
+func compileRuleRegex(rule config.Rule) (ruleRegex, error) {
+	var rx ruleRegex
+	var err error
+	if rule.PathRegex != "" {
+		rx.path, err = regexp.Compile(rule.PathRegex)
+		if err != nil {
+			return rx, fmt.Errorf("path_regex: %w", err)
+		}
+	}
+	if rule.UserAgentRegex != "" {
+		rx.userAgent, err = regexp.Compile(rule.UserAgentRegex)
+		if err != nil {
+			return rx, fmt.Errorf("user_agent_regex: %w", err)
+		}
+	}
+	return rx, nil
+}
+
+func (rs *ruleSet) setRules(rules []config.Rule) error {
+	byService := make(map[string][]config.Rule)
+	ignoreByRule := make(map[string]*config.IPMatcher)
+	regexByRule := make(map[string]ruleRegex)
+	for _, rule := range rules {
+		if len(rule.IgnoreIP) > 0 {
+			m, err := config.NewIPMatcher(rule.IgnoreIP)
+			if err != nil {
+				return fmt.Errorf("rule %q: ignore_ip: %w", rule.Name, err)
+			}
+			ignoreByRule[rule.Name] = m
+		}
+		rx, err := compileRuleRegex(rule)
+		if err != nil {
+			return fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+		regexByRule[rule.Name] = rx
+		byService[rule.ServiceName] = append(byService[rule.ServiceName], rule)
+	}
+	for _, rules := range byService {
+		slices.SortStableFunc(rules, func(a, b config.Rule) int {
+			return cmp.Compare(b.Priority, a.Priority)
+		})
+	}
+	rs.byService = byService
+	rs.ignoreByRule = ignoreByRule
+	rs.regexByRule = regexByRule
+	return nil
+}
+
+func (rs *ruleSet) setServices(services []config.Service) {
+	defaultStatus := make(map[string]config.StringList, len(services))
+	for _, svc := range services {
+		defaultStatus[svc.Name] = svc.DefaultStatus()
+	}
+	rs.defaultStatus = defaultStatus
+}
+
+// matchStatus is rule.MatchStatus with the service's default statuses for a
+// rule that sets none. A service that is not configured is taken as the name
+// of a built-in parser, as the offline commands do.
+func (rs *ruleSet) matchStatus(rule config.Rule, status string) bool {
+	if len(rule.Status) == 0 {
+		def, ok := rs.defaultStatus[rule.ServiceName]
+		if !ok {
+			def = config.Service{Name: rule.ServiceName}.DefaultStatus()
+		}
+		rule.Status = def
+	}
+	return rule.MatchStatus(status)
+}
+
+func (rs *ruleSet) setRuleMatch(mode string) error {
+	switch mode {
+	case config.RuleMatchFirst, config.RuleMatchAll:
+		rs.ruleMatch = mode
+		return nil
+	default:
+		return fmt.Errorf("unknown rule_match %q", mode)
+	}
+}
+
+func (rs *ruleSet) setIgnoreIP(entries []string) error {
+	m, err := config.NewIPMatcher(entries)
+	if err != nil {
+		return fmt.Errorf("ignore_ip: %w", err)
+	}
+	rs.ignoreIP = m
+	return nil
+}
+
+func (rs *ruleSet) setSubnetBan(cfg config.SubnetBan) error {
+	if !cfg.Enabled {
+		rs.subnetBan = nil
+		return nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("subnet_ban: %w", err)
+	}
+	banTime, err := cfg.BanDuration()
+	if err != nil {
+		return fmt.Errorf("subnet_ban: %w", err)
+	}
+	rs.subnetBan = &subnetBan{threshold: cfg.Threshold, banTime: banTime}
+	return nil
+}
+
+func (rs *ruleSet) isIgnored(ip string, rule string) bool {
+	if m, ok := rs.ignoreByRule[rule]; ok {
+		return m.Contains(ip)
+	}
+	return rs.ignoreIP.Contains(ip)
+}
+
+func (rs *ruleSet) matchRule(rule config.Rule, entry *storage.LogEntry) bool {
+	methodMatch := rule.MatchMethod(entry.Method)
+	statusMatch := rs.matchStatus(rule, entry.Status)
+	pathMatch := matchPath(entry.Path, rule.Path)
+	if !methodMatch || !statusMatch || !pathMatch {
+		return false
+	}
+
+	rx := rs.regexByRule[rule.Name]
+	if rx.path != nil && !rx.path.MatchString(entry.Path) {
+		return false
+	}
+	if rx.userAgent != nil && !rx.userAgent.MatchString(entry.UserAgent) {
+		return false
+	}
+	return true
+}
+
+// mismatches lists the fields of rule that entry does not satisfy, in the
+// order matchRule checks them. It is empty exactly when matchRule is true.
+func (rs *ruleSet) mismatches(rule config.Rule, entry *storage.LogEntry) []string {
+	var fields []string
+	if !rule.MatchMethod(entry.Method) {
+		fields = append(fields, FieldMethod)
+	}
+	if !rs.matchStatus(rule, entry.Status) {
+		fields = append(fields, FieldStatus)
+	}
+	if !matchPath(entry.Path, rule.Path) {
+		fields = append(fields, FieldPath)
+	}
+	rx := rs.regexByRule[rule.Name]
+	if rx.path != nil && !rx.path.MatchString(entry.Path) {
+		fields = append(fields, FieldPathRegex)
+	}
+	if rx.userAgent != nil && !rx.userAgent.MatchString(entry.UserAgent) {
+		fields = append(fields, FieldUserAgentRegex)
+	}
+	return fields
+}
+
+func matchPath(path string, rulePath string) bool {
+	if rulePath == "" {
+		return true
+	}
+
+	if strings.HasPrefix(rulePath, "*") {
+		suffix := strings.TrimPrefix(rulePath, "*")
+		return strings.HasSuffix(path, suffix)
+	}
+
+	if strings.HasPrefix(rulePath, "/*") {
+		suffix := strings.TrimPrefix(rulePath, "/*")
+		return strings.HasSuffix(path, suffix)
+	}
+
+	if strings.HasSuffix(rulePath, "*") {
+		prefix := strings.TrimSuffix(rulePath, "*")
+		return strings.HasPrefix(path, prefix)
+	}
+	return path == rulePath
+}
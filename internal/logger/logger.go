@@ -13,7 +13,25 @@ type Logger struct {
 	*slog.Logger
 }
 
+// quiet is set by Quiet.
+var quiet bool
+
+// Quiet makes loggers created afterwards write only warnings and errors, to
+// stderr and not to the daemon log file. Offline commands call it first so
+// their report on stdout stays readable.
+func Quiet() {
+	quiet = true
+}
+
 func New(debug bool) *Logger {
+	if quiet {
+		return &Logger{
+			Logger: slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+				Level: slog.LevelWarn,
+			})),
+		}
+	}
+
 	logDir := "/var/log/banforge"
 	var level slog.Level
 	if debug {
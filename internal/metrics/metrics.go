@@ -21,6 +21,15 @@ func IncBan(service string) {
 	metricsMu.Unlock()
 }
 
+// IncMonitorBan counts a ban recorded in monitor mode, which never reaches
+// the firewall.
+func IncMonitorBan(service string) {
+	metricsMu.Lock()
+	metrics["monitor_ban_count"]++
+	metrics[service+"_monitor_bans"]++
+	metricsMu.Unlock()
+}
+
 func IncUnban(service string) {
 	metricsMu.Lock()
 	metrics["unban_count"]++
@@ -28,6 +37,13 @@ func IncUnban(service string) {
 	metricsMu.Unlock()
 }
 
+func IncBanRefused(source string) {
+	metricsMu.Lock()
+	metrics["ban_refused_count"]++
+	metrics[source+"_ban_refused"]++
+	metricsMu.Unlock()
+}
+
 func IncRuleMatched(rule_name string) {
 	metricsMu.Lock()
 	metrics[rule_name+"_rule_matched"]++
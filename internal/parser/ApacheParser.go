@@ -2,6 +2,7 @@ package parser
 
 import (
 	"regexp"
+	"time"
 
 	"github.com/d3m0k1d/BanForge/internal/logger"
 	"github.com/d3m0k1d/BanForge/internal/metrics"
@@ -9,16 +10,17 @@ import (
 )
 
 type ApacheParser struct {
+	service string
 	pattern *regexp.Regexp
 	logger  *logger.Logger
 }
 
 func NewApacheParser() *ApacheParser {
 	pattern := regexp.MustCompile(
-		`^(\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3})\s+-\s+-\s+\[(.*?)\]\s+"(\w+)\s+(.*?)\s+HTTP/[\d.]+"\s+(\d+)\s+(\d+|-)\s+"(.*?)"\s+"(.*?)"`,
+		`^(\S+)\s+-\s+-\s+\[(.*?)\]\s+"(\w+)\s+(.*?)\s+HTTP/[\d.]+"\s+(\d+)\s+(\d+|-)\s+"(.*?)"\s+"(.*?)"`,
 	)
 	// Groups:
-	// 1: IP
+	// 1: IP (IPv4 or IPv6)
 	// 2: Timestamp
 	// 3: Method (GET, POST, etc.)
 	// 4: Path
@@ -28,6 +30,7 @@ func NewApacheParser() *ApacheParser {
 	// 8: User-Agent
 
 	return &ApacheParser{
+		service: "apache",
 		pattern: pattern,
 		logger:  logger.New(false),
 	}
@@ -36,25 +39,36 @@ func NewApacheParser() *ApacheParser {
 func (p *ApacheParser) Parse(eventCh <-chan Event, resultCh chan<- *storage.LogEntry) {
 	// Group 1: IP, Group 2: Timestamp, Group 3: Method, Group 4: Path, Group 5: Status
 	for event := range eventCh {
-		matches := p.pattern.FindStringSubmatch(event.Data)
+		matches := p.pattern.FindStringSubmatch(event.Message())
 		if matches == nil {
 			continue
 		}
+		ip, ok := normalizeIP(matches[1])
+		if !ok {
+			p.logger.Debug("Skipping apache log entry with invalid client address", "ip", matches[1])
+			continue
+		}
+		logged, ok := parseTime(matches[2], time.Now())
+		if !ok {
+			logged = event.Time(time.Now())
+		}
 		path := matches[4]
 		status := matches[5]
 		method := matches[3]
 
 		resultCh <- &storage.LogEntry{
-			Service: "apache",
-			IP:      matches[1],
-			Path:    path,
-			Status:  status,
-			Method:  method,
+			Service:   p.service,
+			IP:        ip,
+			Path:      path,
+			Status:    status,
+			Method:    method,
+			UserAgent: matches[8],
+			Time:      logged,
 		}
-		metrics.IncParserEvent("apache")
+		metrics.IncParserEvent(p.service)
 		p.logger.Info(
 			"Parsed apache log entry",
-			"ip", matches[1],
+			"ip", ip,
 			"path", path,
 			"status", status,
 			"method", method,
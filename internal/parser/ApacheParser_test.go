@@ -0,0 +1,49 @@
+package parser
+
+import "testing"
+
+func TestApacheParserUserAgent(t *testing.T) {
+	line := `198.51.100.7 - - [17/Oct/2026:10:00:00 +0000] "GET /.env HTTP/1.1" 404 196 "-" "python-requests/2.31.0"`
+	got := parseLine(t, NewApacheParser().Parse, line)
+	if got == nil {
+		t.Fatal("Parse() produced no entry")
+	}
+	if got.UserAgent != "python-requests/2.31.0" {
+		t.Errorf("UserAgent = %q, want %q", got.UserAgent, "python-requests/2.31.0")
+	}
+	if got.Path != "/.env" || got.Status != "404" {
+		t.Errorf("Path, Status = %q, %q, want /.env, 404", got.Path, got.Status)
+	}
+}
+
+func TestApacheParserAddresses(t *testing.T) {
+	tests := []struct {
+		name   string
+		client string
+		want   string // empty: no entry
+	}{
+		{name: "ipv4", client: "198.51.100.7", want: "198.51.100.7"},
+		{name: "ipv6", client: "2001:db8:0:0::7", want: "2001:db8::7"},
+		{name: "ipv4-mapped", client: "::ffff:198.51.100.7", want: "198.51.100.7"},
+		{name: "hostname", client: "client.example.net"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := tt.client + ` - - [17/Oct/2026:10:00:00 +0000] "POST /xmlrpc.php HTTP/1.1" 403 199 "-" "-"`
+			got := parseLine(t, NewApacheParser().Parse, line)
+			if tt.want == "" {
+				if got != nil {
+					t.Fatalf("Parse() = %+v, want no entry", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("Parse() produced no entry")
+			}
+			if got.IP != tt.want {
+				t.Errorf("IP = %q, want %q", got.IP, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"regexp"
+	"time"
 
 	"github.com/d3m0k1d/BanForge/internal/logger"
 	"github.com/d3m0k1d/BanForge/internal/metrics"
@@ -9,43 +10,57 @@ import (
 )
 
 type NginxParser struct {
+	service string
 	pattern *regexp.Regexp
 	logger  *logger.Logger
 }
 
 func NewNginxParser() *NginxParser {
 	pattern := regexp.MustCompile(
-		`^(\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}).*\[(.*?)\]\s+"(\w+)\s+(.*?)\s+HTTP.*"\s+(\d+)`,
+		`^(\S+).*\[(.*?)\]\s+"(\w+)\s+(.*?)\s+HTTP[^"]*"\s+(\d+)(?:\s+\S+\s+"[^"]*"\s+"([^"]*)")?`,
 	)
 	return &NginxParser{
+		service: "nginx",
 		pattern: pattern,
 		logger:  logger.New(false),
 	}
 }
 
 func (p *NginxParser) Parse(eventCh <-chan Event, resultCh chan<- *storage.LogEntry) {
-	// Group 1: IP, Group 2: Timestamp, Group 3: Method, Group 4: Path, Group 5: Status
+	// Group 1: IP, Group 2: Timestamp, Group 3: Method, Group 4: Path, Group 5: Status,
+	// Group 6: User-Agent (combined format only)
 	for event := range eventCh {
-		matches := p.pattern.FindStringSubmatch(event.Data)
+		matches := p.pattern.FindStringSubmatch(event.Message())
 		if matches == nil {
 			continue
 		}
+		ip, ok := normalizeIP(matches[1])
+		if !ok {
+			p.logger.Debug("Skipping nginx log entry with invalid client address", "ip", matches[1])
+			continue
+		}
+		logged, ok := parseTime(matches[2], time.Now())
+		if !ok {
+			logged = event.Time(time.Now())
+		}
 		path := matches[4]
 		status := matches[5]
 		method := matches[3]
 
 		resultCh <- &storage.LogEntry{
-			Service: "nginx",
-			IP:      matches[1],
-			Path:    path,
-			Status:  status,
-			Method:  method,
+			Service:   p.service,
+			IP:        ip,
+			Path:      path,
+			Status:    status,
+			Method:    method,
+			UserAgent: matches[6],
+			Time:      logged,
 		}
-		metrics.IncParserEvent("nginx")
+		metrics.IncParserEvent(p.service)
 		p.logger.Info(
 			"Parsed nginx log entry",
 			"ip",
-			matches[1],
+			ip,
 			"path",
 			path,
 			"status",
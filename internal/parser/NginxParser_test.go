@@ -0,0 +1,163 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/d3m0k1d/BanForge/internal/storage"
+)
+
+// parseLine feeds a single line through parse and returns the entry it
+// produced, or nil when the line was skipped.
+func parseLine(
+	t *testing.T,
+	parse func(<-chan Event, chan<- *storage.LogEntry),
+	line string,
+) *storage.LogEntry {
+	t.Helper()
+	return parseEvent(t, parse, Event{Data: line})
+}
+
+func parseEvent(
+	t *testing.T,
+	parse func(<-chan Event, chan<- *storage.LogEntry),
+	event Event,
+) *storage.LogEntry {
+	t.Helper()
+	eventCh := make(chan Event, 1)
+	resultCh := make(chan *storage.LogEntry, 1)
+	eventCh <- event
+	close(eventCh)
+	parse(eventCh, resultCh)
+	close(resultCh)
+	return <-resultCh
+}
+
+// sameEntry compares two log entries. Times are compared with Equal, and
+// only when want has one, so tables that are not about time can omit it.
+func sameEntry(got, want *storage.LogEntry) bool {
+	if !want.Time.IsZero() && !got.Time.Equal(want.Time) {
+		return false
+	}
+	g, w := *got, *want
+	g.Time, w.Time = time.Time{}, time.Time{}
+	return g == w
+}
+
+func TestNginxParser(t *testing.T) {
+	logged := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		line string
+		want *storage.LogEntry
+	}{
+		{
+			name: "combined",
+			line: `203.0.113.5 - - [17/Oct/2026:10:00:00 +0000] "GET /wp-login.php HTTP/1.1" 404 153 "-" "Mozilla/5.0 (compatible; zgrab/0.x)"`,
+			want: &storage.LogEntry{
+				Service:   "nginx",
+				IP:        "203.0.113.5",
+				Path:      "/wp-login.php",
+				Status:    "404",
+				Method:    "GET",
+				UserAgent: "Mozilla/5.0 (compatible; zgrab/0.x)",
+				Time:      logged,
+			},
+		},
+		{
+			name: "common without user agent",
+			line: `203.0.113.5 - - [17/Oct/2026:10:00:00 +0000] "POST /api HTTP/1.1" 200 12`,
+			want: &storage.LogEntry{
+				Service: "nginx",
+				IP:      "203.0.113.5",
+				Path:    "/api",
+				Status:  "200",
+				Method:  "POST",
+				Time:    logged,
+			},
+		},
+		{
+			name: "ipv6 client",
+			line: `2001:db8::1 - - [17/Oct/2026:10:00:00 +0000] "GET /wp-login.php HTTP/2.0" 404 153 "-" "curl/8.5.0"`,
+			want: &storage.LogEntry{
+				Service:   "nginx",
+				IP:        "2001:db8::1",
+				Path:      "/wp-login.php",
+				Status:    "404",
+				Method:    "GET",
+				UserAgent: "curl/8.5.0",
+				Time:      logged,
+			},
+		},
+		{
+			name: "ipv6 client in long form",
+			line: `2001:0db8:0000:0000:0000:0000:0000:0001 - - [17/Oct/2026:10:00:00 +0000] "GET / HTTP/1.1" 403 12`,
+			want: &storage.LogEntry{
+				Service: "nginx",
+				IP:      "2001:db8::1",
+				Path:    "/",
+				Status:  "403",
+				Method:  "GET",
+				Time:    logged,
+			},
+		},
+		{
+			name: "ipv4-mapped client",
+			line: `::ffff:203.0.113.5 - - [17/Oct/2026:10:00:00 +0000] "GET /admin HTTP/1.1" 401 0`,
+			want: &storage.LogEntry{
+				Service: "nginx",
+				IP:      "203.0.113.5",
+				Path:    "/admin",
+				Status:  "401",
+				Method:  "GET",
+				Time:    logged,
+			},
+		},
+		{
+			name: "hostname instead of address",
+			line: `unix: - - [17/Oct/2026:10:00:00 +0000] "GET / HTTP/1.1" 200 12`,
+		},
+		{
+			name: "out of range ipv4",
+			line: `203.0.113.500 - - [17/Oct/2026:10:00:00 +0000] "GET / HTTP/1.1" 200 12`,
+		},
+		{
+			name: "garbage",
+			line: "not a log line",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseLine(t, NewNginxParser().Parse, tt.line)
+			if tt.want == nil {
+				if got != nil {
+					t.Fatalf("Parse() = %+v, want no entry", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("Parse() produced no entry")
+			}
+			if !sameEntry(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", *got, *tt.want)
+			}
+		})
+	}
+}
+
+func TestNginxParserJournald(t *testing.T) {
+	event := Event{
+		Data: `Mar 07 10:04:05 web1 nginx[90]: 203.0.113.5 - - [07/Mar/2026:10:04:05 +0000] "GET /.git/config HTTP/1.1" 404 153`,
+		Fields: map[string]string{
+			"MESSAGE": `203.0.113.5 - - [07/Mar/2026:10:04:05 +0000] "GET /.git/config HTTP/1.1" 404 153`,
+		},
+	}
+	got := parseEvent(t, NewNginxParser().Parse, event)
+	if got == nil {
+		t.Fatal("Parse() produced no entry")
+	}
+	if got.IP != "203.0.113.5" || got.Path != "/.git/config" {
+		t.Errorf("Parse() = %+v", got)
+	}
+}
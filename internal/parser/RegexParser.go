@@ -0,0 +1,143 @@
+package parser
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"time"
+
+	"github.com/d3m0k1d/BanForge/internal/logger"
+	"github.com/d3m0k1d/BanForge/internal/metrics"
+	"github.com/d3m0k1d/BanForge/internal/storage"
+)
+
+// RegexParser parses lines with named-group patterns, either one from the
+// config or the built-in ones of a parser pack. The groups ip, path, method,
+// status, user, user_agent and time fill the log entry; the first pattern
+// that matches wins. Without a time group the entry gets the event's time.
+type RegexParser struct {
+	service  string
+	patterns []linePattern
+	logger   *logger.Logger
+}
+
+type linePattern struct {
+	re     *regexp.Regexp
+	groups map[string]int
+	// status is used when the pattern has no status group.
+	status string
+}
+
+func newLinePattern(re *regexp.Regexp, status string) (linePattern, error) {
+	groups := make(map[string]int)
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			groups[name] = i
+		}
+	}
+	if _, ok := groups["ip"]; !ok {
+		return linePattern{}, fmt.Errorf("pattern: missing (?P<ip>...) group")
+	}
+	return linePattern{re: re, groups: groups, status: status}, nil
+}
+
+func NewRegexParser(service, pattern string) (*RegexParser, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	lp, err := newLinePattern(re, "")
+	if err != nil {
+		return nil, err
+	}
+	return &RegexParser{
+		service:  service,
+		patterns: []linePattern{lp},
+		logger:   logger.New(false),
+	}, nil
+}
+
+func (p *RegexParser) Parse(eventCh <-chan Event, resultCh chan<- *storage.LogEntry) {
+	for event := range eventCh {
+		entry := matchLine(p.patterns, p.service, event.Message(), p.logger)
+		if entry == nil {
+			continue
+		}
+		if entry.Time.IsZero() {
+			entry.Time = event.Time(time.Now())
+		}
+		resultCh <- entry
+		metrics.IncParserEvent(p.service)
+		p.logger.Info(
+			"Parsed log entry",
+			"service",
+			p.service,
+			"ip",
+			entry.IP,
+			"path",
+			entry.Path,
+			"status",
+			entry.Status,
+		)
+	}
+}
+
+// matchLine builds a log entry from the first of patterns that matches line.
+// Its Time is zero unless the pattern has a time group that parses.
+func matchLine(patterns []linePattern, service, line string, log *logger.Logger) *storage.LogEntry {
+	for _, lp := range patterns {
+		matches := lp.re.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		group := func(name string) string {
+			if i, ok := lp.groups[name]; ok {
+				return matches[i]
+			}
+			return ""
+		}
+
+		ip, ok := normalizeIP(group("ip"))
+		if !ok {
+			log.Warn("Pattern matched an invalid IP", "service", service, "ip", group("ip"))
+			return nil
+		}
+		// Like the ssh parser, the user goes into Path when there is no path.
+		path := group("path")
+		if path == "" {
+			path = group("user")
+		}
+		status := group("status")
+		if status == "" {
+			status = lp.status
+		}
+		var logged time.Time
+		if ts := group("time"); ts != "" {
+			if logged, ok = parseTime(ts, time.Now()); !ok {
+				log.Debug("Unrecognized timestamp, using the event time", "service", service, "time", ts)
+			}
+		}
+
+		return &storage.LogEntry{
+			Service:   service,
+			IP:        ip,
+			Path:      path,
+			Status:    status,
+			Method:    group("method"),
+			UserAgent: group("user_agent"),
+			Time:      logged,
+		}
+	}
+	return nil
+}
+
+// normalizeIP parses an IPv4 or IPv6 address and returns its canonical form,
+// with IPv4-mapped IPv6 addresses (::ffff:a.b.c.d) turned into plain IPv4 so
+// both spellings land in the same ban.
+func normalizeIP(s string) (string, bool) {
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return "", false
+	}
+	return addr.Unmap().String(), true
+}
@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/d3m0k1d/BanForge/internal/storage"
+)
+
+func TestRegexParser(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		line    string
+		want    *storage.LogEntry
+	}{
+		{
+			name:    "postfix sasl",
+			pattern: `warning: [^\[]+\[(?P<ip>[0-9a-fA-F.:]+)\]: SASL (?P<method>\w+) authentication failed`,
+			line:    "Mar  7 10:04:05 mx postfix/smtpd[1201]: warning: unknown[198.51.100.23]: SASL LOGIN authentication failed: UGFzc3dvcmQ6",
+			want:    &storage.LogEntry{Service: "postfix", IP: "198.51.100.23", Method: "LOGIN"},
+		},
+		{
+			name:    "user goes into path",
+			pattern: `login failed for (?P<user>\S+) from (?P<ip>\S+) status=(?P<status>\d+)`,
+			line:    "app: login failed for admin from 2001:db8::7 status=401",
+			want:    &storage.LogEntry{Service: "postfix", IP: "2001:db8::7", Path: "admin", Status: "401"},
+		},
+		{
+			name:    "path wins over user",
+			pattern: `(?P<ip>\S+) (?P<user>\S+) (?P<method>\S+) (?P<path>\S+) "(?P<user_agent>[^"]*)"`,
+			line:    `203.0.113.9 bob POST /login "curl/8.0"`,
+			want: &storage.LogEntry{
+				Service: "postfix", IP: "203.0.113.9", Path: "/login", Method: "POST", UserAgent: "curl/8.0",
+			},
+		},
+		{
+			name:    "ipv4-mapped address",
+			pattern: `from (?P<ip>\S+)`,
+			line:    "from ::ffff:203.0.113.9",
+			want:    &storage.LogEntry{Service: "postfix", IP: "203.0.113.9"},
+		},
+		{
+			name:    "invalid ip is skipped",
+			pattern: `from (?P<ip>\S+)`,
+			line:    "from unknown",
+		},
+		{
+			name:    "no match",
+			pattern: `from (?P<ip>\S+)`,
+			line:    "connect to 203.0.113.9",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewRegexParser("postfix", tt.pattern)
+			if err != nil {
+				t.Fatalf("NewRegexParser() error = %v", err)
+			}
+			got := parseLine(t, p.Parse, tt.line)
+			if tt.want == nil {
+				if got != nil {
+					t.Fatalf("Parse() = %+v, want no entry", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("Parse() produced no entry")
+			}
+			if !sameEntry(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegexParserJournald(t *testing.T) {
+	p, err := NewRegexParser("dovecot", `auth failed.*rip=(?P<ip>[^,]+)`)
+	if err != nil {
+		t.Fatalf("NewRegexParser() error = %v", err)
+	}
+	got := parseEvent(t, p.Parse, Event{
+		Data:   "ignored",
+		Fields: map[string]string{"MESSAGE": "imap-login: Disconnected: auth failed, 1 attempts in 2 secs: user=<a>, method=PLAIN, rip=192.0.2.10, lip=10.0.0.1"},
+	})
+	if got == nil || got.IP != "192.0.2.10" || got.Service != "dovecot" {
+		t.Errorf("Parse() = %+v", got)
+	}
+}
+
+func TestNewRegexParserErrors(t *testing.T) {
+	for _, pattern := range []string{`(?P<ip>\S+`, `(?P<user>\S+) failed`} {
+		if _, err := NewRegexParser("app", pattern); err == nil {
+			t.Errorf("NewRegexParser(%q) expected error", pattern)
+		}
+	}
+}
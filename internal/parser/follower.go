@@ -0,0 +1,198 @@
+package parser
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/d3m0k1d/BanForge/internal/logger"
+	"github.com/d3m0k1d/BanForge/internal/storage"
+)
+
+// maxLineSize caps a single log line. Longer lines are split at this size.
+const maxLineSize = 1024 * 1024
+
+var errStopped = errors.New("scanner stopped")
+
+// follower reads lines appended to a log file like `tail -F`: it starts at
+// the end of the file, reopens the path when logrotate moves the file away
+// and starts over when the file is truncated in place (copytruncate).
+type follower struct {
+	path      string
+	file      *os.File
+	inode     uint64
+	reader    *bufio.Reader
+	offset    int64
+	partial   []byte
+	pollDelay time.Duration
+	stopCh    <-chan struct{}
+	logger    *logger.Logger
+}
+
+// newFollower opens path and positions it at resume if that still refers to
+// the same file. A nil resume starts at the end of the file.
+func newFollower(
+	path string,
+	resume *storage.Position,
+	pollDelay time.Duration,
+	stopCh <-chan struct{},
+	log *logger.Logger,
+) (*follower, error) {
+	// #nosec G304 - path is validated by the caller via validateLogPath()
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	inode := inodeOf(info)
+	start, whence := int64(0), io.SeekEnd
+	if resume != nil {
+		switch {
+		case resume.Inode != inode:
+			// Rotated while we were down: everything in the new file is unread.
+			log.Info("Log file replaced since last run, reading from start", "path", path)
+			whence = io.SeekStart
+		case resume.Offset > info.Size():
+			log.Info("Log file truncated since last run, reading from start", "path", path)
+			whence = io.SeekStart
+		default:
+			start, whence = resume.Offset, io.SeekStart
+		}
+	}
+	offset, err := file.Seek(start, whence)
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to seek log file: %w", err)
+	}
+	return &follower{
+		path:      path,
+		file:      file,
+		inode:     inode,
+		reader:    bufio.NewReader(file),
+		offset:    offset,
+		pollDelay: pollDelay,
+		stopCh:    stopCh,
+		logger:    log,
+	}, nil
+}
+
+func inodeOf(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return st.Ino
+	}
+	return 0
+}
+
+// position returns the inode and the offset just past the last line that
+// next returned.
+func (f *follower) position() storage.Position {
+	return storage.Position{Inode: f.inode, Offset: f.offset - int64(len(f.partial))}
+}
+
+// next blocks until a complete line is available and returns it without the
+// line terminator. It returns errStopped once stopCh is closed.
+func (f *follower) next() (string, error) {
+	for {
+		chunk, err := f.reader.ReadSlice('\n')
+		f.offset += int64(len(chunk))
+		f.partial = append(f.partial, chunk...)
+
+		switch {
+		case err == nil:
+			return f.takeLine(), nil
+		case errors.Is(err, bufio.ErrBufferFull):
+			if len(f.partial) >= maxLineSize {
+				return f.takeLine(), nil
+			}
+		case errors.Is(err, io.EOF):
+			if err := f.wait(); err != nil {
+				return "", err
+			}
+		default:
+			return "", err
+		}
+	}
+}
+
+func (f *follower) takeLine() string {
+	line := strings.TrimSuffix(string(f.partial), "\n")
+	line = strings.TrimSuffix(line, "\r")
+	f.partial = f.partial[:0]
+	return line
+}
+
+// wait sleeps for pollDelay and then checks whether the file was rotated or
+// truncated while we were at its end.
+func (f *follower) wait() error {
+	select {
+	case <-f.stopCh:
+		return errStopped
+	case <-time.After(f.pollDelay):
+	}
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		// Between logrotate's rename and create the path may not exist; keep
+		// the old file until a new one shows up.
+		return nil
+	}
+	current, err := f.file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	if !os.SameFile(info, current) {
+		// Finish what was written to the old file before it was moved.
+		if current.Size() > f.offset {
+			return nil
+		}
+		return f.reopen()
+	}
+	if info.Size() < f.offset {
+		f.logger.Info("Log file truncated, reading from start", "path", f.path)
+		if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to seek log file: %w", err)
+		}
+		f.reset(f.file)
+	}
+	return nil
+}
+
+func (f *follower) reopen() error {
+	// #nosec G304 - path is validated by the caller via validateLogPath()
+	file, err := os.Open(f.path)
+	if err != nil {
+		// Created but not yet readable, try again on the next poll.
+		return nil
+	}
+	f.logger.Info("Log file rotated, reopening", "path", f.path)
+	if err := f.file.Close(); err != nil {
+		f.logger.Error("Failed to close rotated log file", "error", err)
+	}
+	f.reset(file)
+	if info, err := file.Stat(); err == nil {
+		f.inode = inodeOf(info)
+	}
+	return nil
+}
+
+func (f *follower) reset(file *os.File) {
+	f.file = file
+	f.reader.Reset(file)
+	f.offset = 0
+	f.partial = f.partial[:0]
+}
+
+func (f *follower) Close() error {
+	return f.file.Close()
+}
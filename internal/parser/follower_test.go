@@ -0,0 +1,236 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/d3m0k1d/BanForge/internal/logger"
+	"github.com/d3m0k1d/BanForge/internal/storage"
+)
+
+// startFollower follows path and sends every line it reads on the returned
+// channel until the test ends.
+func startFollower(t *testing.T, path string) <-chan string {
+	t.Helper()
+	stopCh := make(chan struct{})
+	f, err := newFollower(path, nil, 10*time.Millisecond, stopCh, logger.New(false))
+	if err != nil {
+		t.Fatalf("newFollower() error = %v", err)
+	}
+
+	lines := make(chan string, 100)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			line, err := f.next()
+			if err != nil {
+				return
+			}
+			lines <- line
+		}
+	}()
+	t.Cleanup(func() {
+		close(stopCh)
+		<-done
+		_ = f.Close()
+	})
+	return lines
+}
+
+func appendLines(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for _, line := range lines {
+		if _, err := f.WriteString(line + "\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func expectLines(t *testing.T, lines <-chan string, want ...string) {
+	t.Helper()
+	for i, w := range want {
+		select {
+		case got := <-lines:
+			if got != w {
+				t.Fatalf("line %d: got %q, want %q", i, truncate(got), truncate(w))
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for line %d %q", i, truncate(w))
+		}
+	}
+	select {
+	case got := <-lines:
+		t.Fatalf("unexpected line %q", truncate(got))
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func truncate(s string) string {
+	if len(s) > 40 {
+		return s[:40] + "..."
+	}
+	return s
+}
+
+func TestFollowerStartsAtEnd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	appendLines(t, path, "old 1", "old 2")
+
+	lines := startFollower(t, path)
+	appendLines(t, path, "new 1")
+	expectLines(t, lines, "new 1")
+}
+
+func TestFollowerPartialAndCRLF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	appendLines(t, path)
+	lines := startFollower(t, path)
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString("half "); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, err := f.WriteString("line\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, lines, "half line")
+}
+
+func TestFollowerLongLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	appendLines(t, path)
+	lines := startFollower(t, path)
+
+	long := strings.Repeat("a", 200*1024)
+	appendLines(t, path, long, "short")
+	expectLines(t, lines, long, "short")
+}
+
+func TestFollowerRotation(t *testing.T) {
+	tests := []struct {
+		name string
+		// rotate may write to the old file; those lines are expected first.
+		rotate   func(t *testing.T, path string)
+		oldLines []string
+	}{
+		{
+			name: "rename and create",
+			rotate: func(t *testing.T, path string) {
+				if err := os.Rename(path, path+".1"); err != nil {
+					t.Fatal(err)
+				}
+				appendLines(t, path+".1", "late write to old file")
+				appendLines(t, path)
+			},
+			oldLines: []string{"late write to old file"},
+		},
+		{
+			name: "copytruncate",
+			rotate: func(t *testing.T, path string) {
+				if err := os.Truncate(path, 0); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "remove and recreate",
+			rotate: func(t *testing.T, path string) {
+				if err := os.Remove(path); err != nil {
+					t.Fatal(err)
+				}
+				time.Sleep(50 * time.Millisecond)
+				appendLines(t, path)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "access.log")
+			appendLines(t, path)
+			lines := startFollower(t, path)
+
+			appendLines(t, path, "before rotation 1", "before rotation 2")
+			expectLines(t, lines, "before rotation 1", "before rotation 2")
+
+			tt.rotate(t, path)
+			expectLines(t, lines, tt.oldLines...)
+			time.Sleep(50 * time.Millisecond)
+
+			appendLines(t, path, "after rotation")
+			expectLines(t, lines, "after rotation")
+		})
+	}
+}
+
+func TestFollowerResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	appendLines(t, path, "seen 1", "seen 2", "missed 1", "missed 2")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inode := inodeOf(info)
+	seen := int64(len("seen 1\nseen 2\n"))
+
+	tests := []struct {
+		name   string
+		resume storage.Position
+		want   []string
+	}{
+		{
+			name:   "same file",
+			resume: storage.Position{Inode: inode, Offset: seen},
+			want:   []string{"missed 1", "missed 2"},
+		},
+		{
+			name:   "rotated while down",
+			resume: storage.Position{Inode: inode + 1, Offset: seen},
+			want:   []string{"seen 1", "seen 2", "missed 1", "missed 2"},
+		},
+		{
+			name:   "truncated while down",
+			resume: storage.Position{Inode: inode, Offset: info.Size() + 100},
+			want:   []string{"seen 1", "seen 2", "missed 1", "missed 2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stopCh := make(chan struct{})
+			defer close(stopCh)
+			f, err := newFollower(path, &tt.resume, 10*time.Millisecond, stopCh, logger.New(false))
+			if err != nil {
+				t.Fatalf("newFollower() error = %v", err)
+			}
+			defer f.Close()
+
+			for i, want := range tt.want {
+				got, err := f.next()
+				if err != nil {
+					t.Fatalf("next() error = %v", err)
+				}
+				if got != want {
+					t.Fatalf("line %d: got %q, want %q", i, got, want)
+				}
+			}
+			if pos := f.position(); pos.Inode != inode || pos.Offset != info.Size() {
+				t.Errorf("position() = %+v, want inode %d offset %d", pos, inode, info.Size())
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/d3m0k1d/BanForge/internal/config"
+	"github.com/d3m0k1d/BanForge/internal/storage"
+)
+
+// JournalFilter selects the journal entries a journald Scanner reads. The
+// set fields are passed to journalctl and combined the way it combines them.
+type JournalFilter struct {
+	Unit        string   // -u
+	Identifiers []string // -t, SYSLOG_IDENTIFIER
+	Matches     []string // FIELD=value match expressions and "+"
+}
+
+func (f JournalFilter) validate() error {
+	if f.Unit == "" && len(f.Identifiers) == 0 && len(f.Matches) == 0 {
+		return fmt.Errorf("journald unit cannot be empty")
+	}
+	if f.Unit != "" {
+		if err := validateJournaldUnit(f.Unit); err != nil {
+			return err
+		}
+	}
+	for _, ident := range f.Identifiers {
+		if !config.JournalIdentifierPattern.MatchString(ident) {
+			return fmt.Errorf("invalid syslog identifier: %s", ident)
+		}
+	}
+	for _, match := range f.Matches {
+		if match != "+" && !config.JournalMatchPattern.MatchString(match) {
+			return fmt.Errorf("invalid journal match: %s", match)
+		}
+	}
+	return nil
+}
+
+func (f JournalFilter) args() []string {
+	var args []string
+	if f.Unit != "" {
+		args = append(args, "--unit="+f.Unit)
+	}
+	for _, ident := range f.Identifiers {
+		args = append(args, "--identifier="+ident)
+	}
+	// Matches are positional and must come after all options.
+	return append(args, f.Matches...)
+}
+
+// source names the filter in the position store. A plain unit keeps the
+// name it had before identifiers and matches were supported.
+func (f JournalFilter) source() string {
+	var parts []string
+	if f.Unit != "" {
+		parts = append(parts, f.Unit)
+	}
+	if len(f.Identifiers) > 0 {
+		parts = append(parts, "-t "+strings.Join(f.Identifiers, ","))
+	}
+	parts = append(parts, f.Matches...)
+	return "journald:" + strings.Join(parts, " ")
+}
+
+// decodeJournalLine turns one `journalctl -o json` record into an event. Its
+// Data is the line `journalctl -o short` would print, so line-based parsers
+// work on either, and its position is the record's cursor.
+func decodeJournalLine(line string) (Event, storage.Position, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(line), &raw); err != nil {
+		return Event{}, storage.Position{}, fmt.Errorf("invalid journal record: %w", err)
+	}
+	fields := make(map[string]string, len(raw))
+	for name, value := range raw {
+		v, err := journalValue(value)
+		if err != nil {
+			return Event{}, storage.Position{}, fmt.Errorf("invalid journal field %s: %w", name, err)
+		}
+		fields[name] = v
+	}
+
+	ts, ok := journalTime(fields)
+	if !ok {
+		ts = time.Now()
+	}
+	ident := fields["SYSLOG_IDENTIFIER"]
+	if pid := fields["_PID"]; pid != "" {
+		ident += "[" + pid + "]"
+	}
+	short := fmt.Sprintf("%s %s %s: %s", ts.Format("Jan 02 15:04:05"), fields["_HOSTNAME"], ident, fields["MESSAGE"])
+	return Event{Data: short, Fields: fields}, storage.Position{Cursor: fields["__CURSOR"]}, nil
+}
+
+// journalValue decodes a field value. journalctl writes it as a string, as an
+// array of bytes if it is not valid UTF-8, or as an array of those if the
+// field occurs more than once, in which case the first value is used.
+func journalValue(raw json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+	var b []int
+	if err := json.Unmarshal(raw, &b); err == nil {
+		buf := make([]byte, len(b))
+		for i, c := range b {
+			buf[i] = byte(c) // #nosec G115 - journalctl writes bytes as 0-255
+		}
+		return string(buf), nil
+	}
+	var multi []json.RawMessage
+	if err := json.Unmarshal(raw, &multi); err == nil && len(multi) > 0 {
+		return journalValue(multi[0])
+	}
+	if string(raw) == "null" {
+		return "", nil
+	}
+	return "", fmt.Errorf("unsupported value %s", raw)
+}
@@ -0,0 +1,153 @@
+package parser
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDecodeJournalLine(t *testing.T) {
+	ts := time.Date(2026, time.March, 7, 10, 4, 5, 0, time.Local)
+	realtime := `"__REALTIME_TIMESTAMP":"` + strconv.FormatInt(ts.UnixMicro(), 10) + `"`
+
+	tests := []struct {
+		name       string
+		line       string
+		want       string
+		wantCursor string
+		wantErr    bool
+	}{
+		{
+			name: "sshd record",
+			line: `{"__CURSOR":"s=1;i=2",` + realtime + `,"_HOSTNAME":"web1","SYSLOG_IDENTIFIER":"sshd-session","_PID":"812",` +
+				`"MESSAGE":"Failed password for root from 203.0.113.7 port 50122 ssh2"}`,
+			want:       "Mar 07 10:04:05 web1 sshd-session[812]: Failed password for root from 203.0.113.7 port 50122 ssh2",
+			wantCursor: "s=1;i=2",
+		},
+		{
+			name:       "no pid",
+			line:       `{"__CURSOR":"s=1;i=3",` + realtime + `,"_HOSTNAME":"web1","SYSLOG_IDENTIFIER":"kernel","MESSAGE":"hello"}`,
+			want:       "Mar 07 10:04:05 web1 kernel: hello",
+			wantCursor: "s=1;i=3",
+		},
+		{
+			name:       "binary message",
+			line:       `{"__CURSOR":"s=1;i=4",` + realtime + `,"_HOSTNAME":"web1","SYSLOG_IDENTIFIER":"app","MESSAGE":[104,105,255]}`,
+			want:       "Mar 07 10:04:05 web1 app: hi\xff",
+			wantCursor: "s=1;i=4",
+		},
+		{
+			name:       "repeated field",
+			line:       `{"__CURSOR":"s=1;i=6",` + realtime + `,"_HOSTNAME":"web1","SYSLOG_IDENTIFIER":["app","app2"],"MESSAGE":"x"}`,
+			want:       "Mar 07 10:04:05 web1 app: x",
+			wantCursor: "s=1;i=6",
+		},
+		{
+			name:    "not json",
+			line:    "-- No entries --",
+			wantErr: true,
+		},
+		{
+			name:    "invalid message",
+			line:    `{"__CURSOR":"s=1;i=5","MESSAGE":{"a":1}}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, pos, err := decodeJournalLine(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeJournalLine() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Data != tt.want {
+				t.Errorf("decodeJournalLine() = %q, want %q", got.Data, tt.want)
+			}
+			if got.Fields["__CURSOR"] != tt.wantCursor {
+				t.Errorf("decodeJournalLine() fields = %v, want __CURSOR %q", got.Fields, tt.wantCursor)
+			}
+			if pos.Cursor != tt.wantCursor {
+				t.Errorf("decodeJournalLine() cursor = %q, want %q", pos.Cursor, tt.wantCursor)
+			}
+		})
+	}
+}
+
+func TestDecodeJournalLineFields(t *testing.T) {
+	line := `{"__CURSOR":"s=1;i=2","__REALTIME_TIMESTAMP":"1772877845000000","_SYSTEMD_UNIT":"ssh.service",` +
+		`"SYSLOG_IDENTIFIER":"sshd","_PID":"812","MESSAGE":"Failed password for root from 203.0.113.7 port 50122 ssh2"}`
+	event, _, err := decodeJournalLine(line)
+	if err != nil {
+		t.Fatalf("decodeJournalLine() error = %v", err)
+	}
+
+	want := map[string]string{
+		"_SYSTEMD_UNIT":        "ssh.service",
+		"SYSLOG_IDENTIFIER":    "sshd",
+		"_PID":                 "812",
+		"__REALTIME_TIMESTAMP": "1772877845000000",
+	}
+	for name, value := range want {
+		if event.Fields[name] != value {
+			t.Errorf("Fields[%s] = %q, want %q", name, event.Fields[name], value)
+		}
+	}
+	if msg := event.Message(); msg != "Failed password for root from 203.0.113.7 port 50122 ssh2" {
+		t.Errorf("Message() = %q", msg)
+	}
+}
+
+func TestJournalFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		filter     JournalFilter
+		wantArgs   []string
+		wantSource string
+		wantErr    bool
+	}{
+		{
+			name:       "unit",
+			filter:     JournalFilter{Unit: "sshd"},
+			wantArgs:   []string{"--unit=sshd"},
+			wantSource: "journald:sshd",
+		},
+		{
+			name:       "identifiers",
+			filter:     JournalFilter{Identifiers: []string{"sshd", "sshd-session"}},
+			wantArgs:   []string{"--identifier=sshd", "--identifier=sshd-session"},
+			wantSource: "journald:-t sshd,sshd-session",
+		},
+		{
+			name:       "unit and matches",
+			filter:     JournalFilter{Unit: "nginx", Matches: []string{"PRIORITY=6", "+", "_COMM=nginx"}},
+			wantArgs:   []string{"--unit=nginx", "PRIORITY=6", "+", "_COMM=nginx"},
+			wantSource: "journald:nginx PRIORITY=6 + _COMM=nginx",
+		},
+		{name: "empty", filter: JournalFilter{}, wantErr: true},
+		{name: "option as identifier", filter: JournalFilter{Identifiers: []string{"-f"}}, wantErr: true},
+		{name: "option as match", filter: JournalFilter{Matches: []string{"--output=cat"}}, wantErr: true},
+		{name: "bad unit", filter: JournalFilter{Unit: "a;b", Identifiers: []string{"sshd"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.filter.validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := tt.filter.args(); !slices.Equal(got, tt.wantArgs) {
+				t.Errorf("args() = %q, want %q", got, tt.wantArgs)
+			}
+			if got := tt.filter.source(); got != tt.wantSource {
+				t.Errorf("source() = %q, want %q", got, tt.wantSource)
+			}
+		})
+	}
+}
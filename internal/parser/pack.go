@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"regexp"
+
+	"github.com/d3m0k1d/BanForge/internal/logger"
+)
+
+// Statuses reported by the pack parsers, for use in Rule.Status.
+const (
+	StatusFailed   = "Failed"
+	StatusTLSError = "TLSError"
+)
+
+// packPattern is a built-in line pattern and the status of the entries it
+// produces. The patterns match the message part only, so they work on
+// syslog files and journald alike.
+type packPattern struct {
+	expr   string
+	status string
+}
+
+// ipGroup matches an IPv4 or IPv6 address; candidates are checked with
+// netip before an entry is produced.
+const ipGroup = `(?P<ip>[0-9A-Fa-f.:]*[0-9A-Fa-f])`
+
+var postfixPatterns = []packPattern{
+	// warning: unknown[198.51.100.23]: SASL LOGIN authentication failed: UGFzc3dvcmQ6, sasl_username=bob
+	{`warning: [^\s\[]*\[` + ipGroup + `\]: SASL (?P<method>[\w-]+) authentication failed(?:.*sasl_username=(?P<user>\S+))?`, StatusFailed},
+}
+
+var dovecotPatterns = []packPattern{
+	// imap-login: Disconnected (auth failed, 1 attempts in 2 secs): user=<bob>, method=PLAIN, rip=198.51.100.23, lip=...
+	{`-login: .*\(auth failed, \d+ attempts? in \d+ secs\): user=<(?P<user>[^>]*)>(?:, method=(?P<method>[\w-]+))?, rip=` + ipGroup, StatusFailed},
+}
+
+var vsftpdPatterns = []packPattern{
+	// [pid 1234] [bob] FAIL LOGIN: Client "::ffff:198.51.100.23"
+	{`\[(?P<user>[^\]]*)\] FAIL LOGIN: Client "` + ipGroup + `"`, StatusFailed},
+}
+
+var proftpdPatterns = []packPattern{
+	// ftp.example.com (198.51.100.23[198.51.100.23]) - USER bob (Login failed): Incorrect password
+	// ftp.example.com (198.51.100.23[198.51.100.23]) - USER bob: no such user found from 198.51.100.23 [...]
+	{`\(\S*\[` + ipGroup + `\]\) - USER (?P<user>[^\s:]+)(?: \(Login failed\):|: no such user found)`, StatusFailed},
+}
+
+var mysqlPatterns = []packPattern{
+	// [Warning] Access denied for user 'root'@'198.51.100.23' (using password: YES)
+	{`Access denied for user '(?P<user>[^']*)'@'` + ipGroup + `'`, StatusFailed},
+}
+
+var postgresqlPatterns = []packPattern{
+	// Needs the client address in log_line_prefix, e.g. '%m [%p] %h %q%u@%d ' or %r:
+	// [1234] 198.51.100.23(51234) bob@app FATAL:  password authentication failed for user "bob"
+	{`(?:^|\s)` + ipGroup + `(?:\(\d+\))?\s+(?:\S+@\S*\s+)?FATAL:\s+password authentication failed for user "(?P<user>[^"]*)"`, StatusFailed},
+}
+
+var giteaPatterns = []packPattern{
+	// Failed authentication attempt for bob from 198.51.100.23:51234: user does not exist
+	{`Failed authentication attempt for (?P<user>.+?) from \[?` + ipGroup + `\]?:\d+:`, StatusFailed},
+}
+
+var grafanaPatterns = []packPattern{
+	// msg="Invalid username or password" ... uname=bob ... remote_addr=198.51.100.23
+	{`msg="Invalid username or password".*?\buname=(?P<user>\S*).*?\bremote_addr=\[?` + ipGroup, StatusFailed},
+	{`msg="Invalid username or password".*?\bremote_addr=\[?` + ipGroup, StatusFailed},
+	// msg="Request Completed" method=POST path=/login status=401 remote_addr=198.51.100.23
+	{`msg="Request Completed" method=(?P<method>POST) path=(?P<path>/login) status=401 remote_addr=\[?` + ipGroup, StatusFailed},
+}
+
+var openvpnPatterns = []packPattern{
+	// 198.51.100.23:51234 TLS Error: TLS handshake failed
+	// bob/198.51.100.23:51234 TLS Auth Error: Auth Username/Password verification failed for peer
+	{`(?:^|[\s/])(?:\[AF_INET6?\])?` + ipGroup + `:\d+ TLS Error:`, StatusTLSError},
+	{`(?:^|[\s/])(?:\[AF_INET6?\])?` + ipGroup + `:\d+ TLS Auth Error:`, StatusFailed},
+	// TLS Error: incoming packet authentication failed from [AF_INET]198.51.100.23:51234
+	{`TLS Error: .* from (?:\[AF_INET6?\])?` + ipGroup + `:\d+`, StatusTLSError},
+}
+
+// newPackParser returns a factory for a RegexParser over the given built-in
+// patterns. The patterns are compiled once, when the registry is built.
+func newPackParser(patterns []packPattern) factory {
+	compiled := compilePatterns(patterns)
+	return func(service string) Parser {
+		return &RegexParser{
+			service:  service,
+			patterns: compiled,
+			logger:   logger.New(false),
+		}
+	}
+}
+
+func compilePatterns(patterns []packPattern) []linePattern {
+	compiled := make([]linePattern, len(patterns))
+	for i, pp := range patterns {
+		lp, err := newLinePattern(regexp.MustCompile(pp.expr), pp.status)
+		if err != nil {
+			panic(err)
+		}
+		compiled[i] = lp
+	}
+	return compiled
+}
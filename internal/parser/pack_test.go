@@ -0,0 +1,219 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/d3m0k1d/BanForge/internal/storage"
+)
+
+func TestPackParsers(t *testing.T) {
+	tests := []struct {
+		parser string
+		name   string
+		line   string
+		want   *storage.LogEntry // Service is filled in from parser
+	}{
+		{
+			parser: "postfix",
+			name:   "sasl login",
+			line:   "Mar  7 10:04:05 mx postfix/smtpd[1201]: warning: unknown[198.51.100.23]: SASL LOGIN authentication failed: UGFzc3dvcmQ6",
+			want:   &storage.LogEntry{IP: "198.51.100.23", Status: "Failed", Method: "LOGIN"},
+		},
+		{
+			parser: "postfix",
+			name:   "sasl plain with username",
+			line:   "Mar  7 10:04:05 mx postfix/submission/smtpd[1201]: warning: mail.example.net[2001:db8::25]: SASL PLAIN authentication failed: authentication failure, sasl_username=bob@example.org",
+			want:   &storage.LogEntry{IP: "2001:db8::25", Path: "bob@example.org", Status: "Failed", Method: "PLAIN"},
+		},
+		{
+			parser: "postfix",
+			name:   "normal connect",
+			line:   "Mar  7 10:04:05 mx postfix/smtpd[1201]: connect from unknown[198.51.100.23]",
+		},
+		{
+			parser: "dovecot",
+			name:   "imap auth failed",
+			line:   "Mar  7 10:04:05 mx dovecot: imap-login: Disconnected (auth failed, 1 attempts in 2 secs): user=<bob>, method=PLAIN, rip=198.51.100.23, lip=10.0.0.1, TLS, session=<3bTrMqUFKtLGM2Rk>",
+			want:   &storage.LogEntry{IP: "198.51.100.23", Path: "bob", Status: "Failed", Method: "PLAIN"},
+		},
+		{
+			parser: "dovecot",
+			name:   "pop3 aborted login without method",
+			line:   "Mar  7 10:04:05 mx dovecot: pop3-login: Aborted login (auth failed, 3 attempts in 14 secs): user=<admin>, rip=198.51.100.24, lip=10.0.0.1, session=<abc>",
+			want:   &storage.LogEntry{IP: "198.51.100.24", Path: "admin", Status: "Failed"},
+		},
+		{
+			parser: "dovecot",
+			name:   "2.3 connection closed",
+			line:   "Mar  7 10:04:05 mx dovecot: imap-login: Disconnected: Connection closed (auth failed, 2 attempts in 6 secs): user=<info>, method=LOGIN, rip=2001:db8::77, lip=2001:db8::1, TLS, session=<x>",
+			want:   &storage.LogEntry{IP: "2001:db8::77", Path: "info", Status: "Failed", Method: "LOGIN"},
+		},
+		{
+			parser: "dovecot",
+			name:   "successful login",
+			line:   "Mar  7 10:04:05 mx dovecot: imap-login: Login: user=<bob>, method=PLAIN, rip=198.51.100.23, lip=10.0.0.1, mpid=1234, TLS",
+		},
+		{
+			parser: "vsftpd",
+			name:   "fail login",
+			line:   `Sat Mar  7 10:04:05 2026 [pid 4211] [bob] FAIL LOGIN: Client "198.51.100.23"`,
+			want:   &storage.LogEntry{IP: "198.51.100.23", Path: "bob", Status: "Failed"},
+		},
+		{
+			parser: "vsftpd",
+			name:   "ipv4-mapped client",
+			line:   `Sat Mar  7 10:04:05 2026 [pid 4211] [anonymous] FAIL LOGIN: Client "::ffff:198.51.100.23"`,
+			want:   &storage.LogEntry{IP: "198.51.100.23", Path: "anonymous", Status: "Failed"},
+		},
+		{
+			parser: "vsftpd",
+			name:   "ok login",
+			line:   `Sat Mar  7 10:04:05 2026 [pid 4211] [bob] OK LOGIN: Client "198.51.100.23"`,
+		},
+		{
+			parser: "proftpd",
+			name:   "incorrect password",
+			line:   "Mar  7 10:04:05 ftp proftpd[1234]: ftp.example.com (198.51.100.23[198.51.100.23]) - USER bob (Login failed): Incorrect password",
+			want:   &storage.LogEntry{IP: "198.51.100.23", Path: "bob", Status: "Failed"},
+		},
+		{
+			parser: "proftpd",
+			name:   "no such user",
+			line:   "Mar  7 10:04:05 ftp proftpd[1234]: ftp.example.com (client.example.net[198.51.100.23]) - USER admin: no such user found from client.example.net [198.51.100.23] to 10.0.0.5:21",
+			want:   &storage.LogEntry{IP: "198.51.100.23", Path: "admin", Status: "Failed"},
+		},
+		{
+			parser: "mysql",
+			name:   "mysql 8",
+			line:   "2026-03-07T10:04:05.123456Z 12 [Note] [MY-010926] [Server] Access denied for user 'root'@'198.51.100.23' (using password: YES)",
+			want:   &storage.LogEntry{IP: "198.51.100.23", Path: "root", Status: "Failed"},
+		},
+		{
+			parser: "mariadb",
+			name:   "mariadb",
+			line:   "2026-03-07 10:04:05 31 [Warning] Access denied for user 'admin'@'2001:db8::9' (using password: NO)",
+			want:   &storage.LogEntry{IP: "2001:db8::9", Path: "admin", Status: "Failed"},
+		},
+		{
+			parser: "mysql",
+			name:   "hostname instead of address",
+			line:   "2026-03-07 10:04:05 31 [Warning] Access denied for user 'admin'@'localhost' (using password: YES)",
+		},
+		{
+			parser: "postgresql",
+			name:   "prefix with %h",
+			line:   `2026-03-07 10:04:05.123 UTC [1234] 198.51.100.23 postgres@postgres FATAL:  password authentication failed for user "postgres"`,
+			want:   &storage.LogEntry{IP: "198.51.100.23", Path: "postgres", Status: "Failed"},
+		},
+		{
+			parser: "postgresql",
+			name:   "prefix with %r",
+			line:   `2026-03-07 10:04:05.123 UTC [1234] 2001:db8::5(51234) app@app FATAL:  password authentication failed for user "app"`,
+			want:   &storage.LogEntry{IP: "2001:db8::5", Path: "app", Status: "Failed"},
+		},
+		{
+			parser: "postgresql",
+			name:   "default prefix without address",
+			line:   `2026-03-07 10:04:05.123 UTC [1234] postgres@postgres FATAL:  password authentication failed for user "postgres"`,
+		},
+		{
+			parser: "gitea",
+			name:   "unknown user",
+			line:   "2026/03/07 10:04:05 ...rs/web/auth/auth.go:226:SignInPost() [I] Failed authentication attempt for bob from 198.51.100.23:51234: user does not exist [uid: 0, name: bob]",
+			want:   &storage.LogEntry{IP: "198.51.100.23", Path: "bob", Status: "Failed"},
+		},
+		{
+			parser: "gitea",
+			name:   "ipv6 with brackets",
+			line:   "2026/03/07 10:04:05 ...rs/web/auth/auth.go:226:SignInPost() [I] Failed authentication attempt for admin from [2001:db8::3]:51234: user's password is invalid [uid: 1, name: admin]",
+			want:   &storage.LogEntry{IP: "2001:db8::3", Path: "admin", Status: "Failed"},
+		},
+		{
+			parser: "grafana",
+			name:   "invalid username or password",
+			line:   `t=2026-03-07T10:04:05+0000 lvl=eror msg="Invalid username or password" logger=context userId=0 orgId=0 uname=admin error="invalid username or password" remote_addr=198.51.100.23`,
+			want:   &storage.LogEntry{IP: "198.51.100.23", Path: "admin", Status: "Failed"},
+		},
+		{
+			parser: "grafana",
+			name:   "login request completed with 401",
+			line:   `logger=context userId=0 orgId=0 uname= t=2026-03-07T10:04:05.12+00:00 level=info msg="Request Completed" method=POST path=/login status=401 remote_addr=198.51.100.23 time_ms=85 duration=85.1ms size=42 referer= handler=/login status_source=server`,
+			want:   &storage.LogEntry{IP: "198.51.100.23", Path: "/login", Status: "Failed", Method: "POST"},
+		},
+		{
+			parser: "grafana",
+			name:   "successful request",
+			line:   `logger=context userId=1 orgId=1 uname=admin t=2026-03-07T10:04:05.12+00:00 level=info msg="Request Completed" method=GET path=/api/search status=200 remote_addr=198.51.100.23`,
+		},
+		{
+			parser: "openvpn",
+			name:   "tls handshake failed",
+			line:   "Mar  7 10:04:05 vpn openvpn[812]: 198.51.100.23:51234 TLS Error: TLS handshake failed",
+			want:   &storage.LogEntry{IP: "198.51.100.23", Status: "TLSError"},
+		},
+		{
+			parser: "openvpn",
+			name:   "tls-auth hmac failure",
+			line:   "Mar  7 10:04:05 vpn openvpn[812]: TLS Error: incoming packet authentication failed from [AF_INET]198.51.100.23:51234",
+			want:   &storage.LogEntry{IP: "198.51.100.23", Status: "TLSError"},
+		},
+		{
+			parser: "openvpn",
+			name:   "auth username password",
+			line:   "Mar  7 10:04:05 vpn openvpn[812]: bob/198.51.100.23:51234 TLS Auth Error: Auth Username/Password verification failed for peer",
+			want:   &storage.LogEntry{IP: "198.51.100.23", Status: "Failed"},
+		},
+		{
+			parser: "openvpn",
+			name:   "ipv6 peer",
+			line:   "Mar  7 10:04:05 vpn openvpn[812]: [AF_INET6]2001:db8::44:51234 TLS Error: TLS key negotiation failed to occur within 60 seconds (check your network connectivity)",
+			want:   &storage.LogEntry{IP: "2001:db8::44", Status: "TLSError"},
+		},
+		{
+			parser: "openvpn",
+			name:   "peer connection initiated",
+			line:   "Mar  7 10:04:05 vpn openvpn[812]: 198.51.100.23:51234 [bob] Peer Connection Initiated with [AF_INET]198.51.100.23:51234",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.parser+"/"+tt.name, func(t *testing.T) {
+			p, err := New(tt.parser, tt.parser)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			got := parseLine(t, p.Parse, tt.line)
+			if tt.want == nil {
+				if got != nil {
+					t.Fatalf("Parse() = %+v, want no entry", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("Parse() produced no entry")
+			}
+			want := *tt.want
+			want.Service = tt.parser
+			if !sameEntry(got, &want) {
+				t.Errorf("Parse() = %+v, want %+v", *got, want)
+			}
+		})
+	}
+}
+
+func TestPackParserJournald(t *testing.T) {
+	p, err := New("postfix", "mail")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	got := parseEvent(t, p.Parse, Event{
+		Data: "ignored",
+		Fields: map[string]string{
+			"SYSLOG_IDENTIFIER": "postfix/smtpd",
+			"MESSAGE":           "warning: unknown[198.51.100.23]: SASL LOGIN authentication failed: UGFzc3dvcmQ6",
+		},
+	})
+	if got == nil || got.IP != "198.51.100.23" || got.Service != "mail" {
+		t.Errorf("Parse() = %+v", got)
+	}
+}
@@ -2,7 +2,9 @@ package parser
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -13,21 +15,62 @@ import (
 
 	"github.com/d3m0k1d/BanForge/internal/logger"
 	"github.com/d3m0k1d/BanForge/internal/metrics"
+	"github.com/d3m0k1d/BanForge/internal/storage"
 )
 
 type Event struct {
 	Data string
+	// Fields holds the journal fields of an event read from journald, such
+	// as MESSAGE, SYSLOG_IDENTIFIER, _PID and __REALTIME_TIMESTAMP. It is
+	// nil for events read from files.
+	Fields map[string]string
 }
 
+// Message returns the journal MESSAGE of a journald event and the raw line
+// otherwise.
+func (e Event) Message() string {
+	if msg, ok := e.Fields["MESSAGE"]; ok {
+		return msg
+	}
+	return e.Data
+}
+
+// Time returns when the event was logged: the journald receive time, or the
+// timestamp a line read from a file starts with. It is zero if there is none.
+func (e Event) Time(now time.Time) time.Time {
+	if e.Fields != nil {
+		t, _ := journalTime(e.Fields)
+		return t
+	}
+	t, _ := leadingTime(e.Data, now)
+	return t
+}
+
+// Positions persists how far each log source has been read, so a restarted
+// daemon picks up lines that were logged while it was down.
+type Positions interface {
+	Load(source string) (storage.Position, bool, error)
+	Save(pos storage.Position) error
+}
+
+// positionSaveInterval is how often a Scanner persists its position while
+// running. The final position is also saved when it stops.
+const positionSaveInterval = time.Second
+
 type Scanner struct {
-	scanner   *bufio.Scanner
+	next      func() (Event, storage.Position, error)
+	source    string
+	positions Positions
+	posMu     sync.Mutex
+	pos       storage.Position
+	dirty     bool
 	ch        chan Event
 	stopCh    chan struct{}
 	doneCh    chan struct{}
 	stopOnce  sync.Once
 	logger    *logger.Logger
 	cmd       *exec.Cmd
-	file      *os.File
+	tail      *follower
 	pollDelay time.Duration
 }
 
@@ -75,41 +118,70 @@ func validateJournaldUnit(unit string) error {
 	return nil
 }
 
-func NewScannerTail(path string) (*Scanner, error) {
+// NewScannerTail follows the file at path. If positions holds a position for
+// it, reading resumes there; otherwise it starts at the end of the file.
+func NewScannerTail(path string, positions Positions) (*Scanner, error) {
 	if err := validateLogPath(path); err != nil {
 		return nil, fmt.Errorf("invalid log path: %w", err)
 	}
 
-	// #nosec G204 - path is validated above via validateLogPath()
-	cmd := exec.Command("tail", "-F", "-n", "10", path)
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, err
-	}
-
-	if err := cmd.Start(); err != nil {
-		return nil, err
-	}
-
-	return &Scanner{
-		scanner:   bufio.NewScanner(stdout),
+	s := &Scanner{
+		source:    "file:" + path,
+		positions: positions,
 		ch:        make(chan Event, 100),
 		stopCh:    make(chan struct{}),
 		doneCh:    make(chan struct{}),
 		logger:    logger.New(false),
-		file:      nil,
-		cmd:       cmd,
 		pollDelay: 100 * time.Millisecond,
-	}, nil
+	}
+	resume := loadPosition(positions, s.source, s.logger)
+	tail, err := newFollower(path, resume, s.pollDelay, s.stopCh, s.logger)
+	if err != nil {
+		return nil, err
+	}
+	s.tail = tail
+	s.next = func() (Event, storage.Position, error) {
+		line, err := tail.next()
+		return Event{Data: line}, tail.position(), err
+	}
+	return s, nil
 }
 
-func NewScannerJournald(unit string) (*Scanner, error) {
-	if err := validateJournaldUnit(unit); err != nil {
-		return nil, fmt.Errorf("invalid journald unit: %w", err)
+// NewScannerJournald follows the journal entries selected by filter. If
+// positions holds a cursor for it, reading resumes after that entry;
+// otherwise only new entries are read.
+func NewScannerJournald(filter JournalFilter, positions Positions) (*Scanner, error) {
+	if err := filter.validate(); err != nil {
+		return nil, fmt.Errorf("invalid journald filter: %w", err)
 	}
 
-	// #nosec G204 - unit is validated above via validateJournaldUnit()
-	cmd := exec.Command("journalctl", "-u", unit, "-f", "-n", "0", "-o", "short", "--no-pager")
+	source := filter.source()
+	args := append(filter.args(), "-f", "-o", "json", "--no-pager")
+	resume := loadPosition(positions, source, logger.New(false))
+	if resume != nil && resume.Cursor != "" {
+		args = append(args, "--after-cursor="+resume.Cursor)
+	} else {
+		args = append(args, "-n", "0")
+	}
+
+	// #nosec G204 - filter is validated above via JournalFilter.validate()
+	cmd := exec.Command("journalctl", args...)
+	s, err := newScannerCmd(cmd, decodeJournalLine)
+	if err != nil {
+		return nil, err
+	}
+	s.source = source
+	s.positions = positions
+	return s, nil
+}
+
+// newScannerCmd starts cmd and emits each line it writes to stdout. decode
+// turns a raw line into an event and its position; lines it rejects are
+// skipped. A nil decode passes lines through unchanged.
+func newScannerCmd(
+	cmd *exec.Cmd,
+	decode func(string) (Event, storage.Position, error),
+) (*Scanner, error) {
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return nil, err
@@ -119,24 +191,118 @@ func NewScannerJournald(unit string) (*Scanner, error) {
 		return nil, err
 	}
 
+	log := logger.New(false)
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
 	return &Scanner{
-		scanner:   bufio.NewScanner(stdout),
+		next: func() (Event, storage.Position, error) {
+			for scanner.Scan() {
+				if decode == nil {
+					return Event{Data: scanner.Text()}, storage.Position{}, nil
+				}
+				event, pos, err := decode(scanner.Text())
+				if err != nil {
+					log.Error("Failed to decode line", "error", err)
+					metrics.IncError()
+					continue
+				}
+				return event, pos, nil
+			}
+			if err := scanner.Err(); err != nil {
+				return Event{}, storage.Position{}, err
+			}
+			return Event{}, storage.Position{}, io.EOF
+		},
 		ch:        make(chan Event, 100),
 		stopCh:    make(chan struct{}),
 		doneCh:    make(chan struct{}),
-		logger:    logger.New(false),
+		logger:    log,
 		cmd:       cmd,
-		file:      nil,
 		pollDelay: 100 * time.Millisecond,
 	}, nil
 }
 
+// loadPosition returns the stored position of source, or nil if there is
+// none. Errors are logged and treated as no position.
+func loadPosition(positions Positions, source string, log *logger.Logger) *storage.Position {
+	if positions == nil {
+		return nil
+	}
+	pos, ok, err := positions.Load(source)
+	if err != nil {
+		log.Error("Failed to load read position, starting at the end", "source", source, "error", err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	log.Info("Resuming from saved position", "source", source)
+	return &pos
+}
+
+func (s *Scanner) setPosition(pos storage.Position) {
+	s.posMu.Lock()
+	s.pos = pos
+	s.dirty = true
+	s.posMu.Unlock()
+}
+
+func (s *Scanner) savePosition() {
+	s.posMu.Lock()
+	pos, dirty := s.pos, s.dirty
+	s.dirty = false
+	s.posMu.Unlock()
+
+	if !dirty {
+		return
+	}
+	pos.Source = s.source
+	if err := s.positions.Save(pos); err != nil {
+		s.logger.Error("Failed to save read position", "source", s.source, "error", err)
+		metrics.IncError()
+	}
+}
+
+// savePositions saves the position every positionSaveInterval until stop is
+// closed.
+func (s *Scanner) savePositions(stop <-chan struct{}) {
+	ticker := time.NewTicker(positionSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.savePosition()
+		}
+	}
+}
+
 func (s *Scanner) Start() {
 	s.logger.Info("Scanner started")
 
 	go func() {
 		defer close(s.ch)
 		defer close(s.doneCh)
+		if s.tail != nil {
+			defer func() {
+				if err := s.tail.Close(); err != nil {
+					s.logger.Error("Failed to close file", "err", err)
+				}
+			}()
+		}
+		if s.positions != nil {
+			stopSaver, saverDone := make(chan struct{}), make(chan struct{})
+			go func() {
+				defer close(saverDone)
+				s.savePositions(stopSaver)
+			}()
+			defer func() {
+				close(stopSaver)
+				<-saverDone
+				s.savePosition()
+			}()
+		}
 
 		for {
 			select {
@@ -146,19 +312,23 @@ func (s *Scanner) Start() {
 			default:
 			}
 
-			if !s.scanner.Scan() {
-				if err := s.scanner.Err(); err != nil {
-					s.logger.Error("Scanner error", "error", err)
-					metrics.IncError()
-				}
+			event, pos, err := s.next()
+			switch {
+			case errors.Is(err, errStopped):
+				s.logger.Info("Scanner stopped")
+				return
+			case errors.Is(err, io.EOF):
 				s.logger.Info("Scanner stopped: EOF")
 				return
+			case err != nil:
+				s.logger.Error("Scanner error", "error", err)
+				metrics.IncError()
+				return
 			}
 
 			metrics.IncScannerEvent("scanner")
-			s.ch <- Event{
-				Data: s.scanner.Text(),
-			}
+			s.ch <- event
+			s.setPosition(pos)
 		}
 	}()
 }
@@ -178,12 +348,6 @@ func (s *Scanner) Stop() {
 		}
 	}
 
-	if s.file != nil {
-		if err := s.file.Close(); err != nil {
-			s.logger.Error("Failed to close file", "err", err)
-		}
-	}
-
 	select {
 	case <-s.doneCh:
 	case <-time.After(2 * time.Second):
@@ -2,9 +2,13 @@ package parser
 
 import (
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/d3m0k1d/BanForge/internal/storage"
 )
 
 func TestNewScannerTail(t *testing.T) {
@@ -16,7 +20,7 @@ func TestNewScannerTail(t *testing.T) {
 	defer os.Remove(file.Name())
 	file.Close()
 
-	scanner, err := NewScannerTail(file.Name())
+	scanner, err := NewScannerTail(file.Name(), nil)
 	if err != nil {
 		t.Fatalf("NewScannerTail() error = %v", err)
 	}
@@ -25,14 +29,15 @@ func TestNewScannerTail(t *testing.T) {
 		t.Fatal("Scanner is nil")
 	}
 
-	if scanner.cmd == nil {
-		t.Fatal("cmd is nil")
+	if scanner.tail == nil {
+		t.Fatal("tail is nil")
 	}
 
-	if scanner.cmd.Process == nil {
-		t.Fatal("process is nil")
+	if scanner.cmd != nil {
+		t.Fatal("file scanner should not start a process")
 	}
 
+	scanner.Start()
 	scanner.Stop()
 }
 
@@ -82,7 +87,7 @@ func TestScannerTailEvents(t *testing.T) {
 			file.Close()
 			defer os.Remove(filePath)
 
-			scanner, err := NewScannerTail(filePath)
+			scanner, err := NewScannerTail(filePath, nil)
 			if err != nil {
 				t.Fatalf("NewScannerTail() error = %v", err)
 			}
@@ -151,7 +156,7 @@ func TestScannerStop(t *testing.T) {
 	file.Close()
 	defer os.Remove(filePath)
 
-	scanner, err := NewScannerTail(filePath)
+	scanner, err := NewScannerTail(filePath, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -161,11 +166,6 @@ func TestScannerStop(t *testing.T) {
 
 	scanner.Stop()
 
-	err = scanner.cmd.Process.Signal(os.Signal(nil))
-	if err == nil {
-		t.Error("Process still alive after Stop()")
-	}
-
 	select {
 	case _, ok := <-scanner.Events():
 		if ok {
@@ -176,15 +176,7 @@ func TestScannerStop(t *testing.T) {
 	}
 }
 func TestScannerStopsOnProcessExit(t *testing.T) {
-	file, err := os.CreateTemp("", "test-*.log")
-	if err != nil {
-		t.Fatal(err)
-	}
-	filePath := file.Name()
-	file.Close()
-	defer os.Remove(filePath)
-
-	scanner, err := NewScannerTail(filePath)
+	scanner, err := newScannerCmd(exec.Command("sleep", "10"), nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -193,7 +185,7 @@ func TestScannerStopsOnProcessExit(t *testing.T) {
 	time.Sleep(100 * time.Millisecond)
 
 	if err := scanner.cmd.Process.Kill(); err != nil {
-		t.Fatalf("Failed to kill tail process: %v", err)
+		t.Fatalf("Failed to kill process: %v", err)
 	}
 
 	select {
@@ -224,13 +216,13 @@ func TestMultipleScanners(t *testing.T) {
 	file2.Close()
 	defer os.Remove(path2)
 
-	scanner1, err := NewScannerTail(path1)
+	scanner1, err := NewScannerTail(path1, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer scanner1.Stop()
 
-	scanner2, err := NewScannerTail(path2)
+	scanner2, err := NewScannerTail(path2, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -294,7 +286,7 @@ func BenchmarkScanner(b *testing.B) {
 	file.Close()
 	defer os.Remove(filePath)
 
-	scanner, err := NewScannerTail(filePath)
+	scanner, err := NewScannerTail(filePath, nil)
 	if err != nil {
 		b.Fatal(err)
 	}
@@ -471,7 +463,7 @@ func TestNewScannerTailValidation(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := NewScannerTail(tt.path)
+			_, err := NewScannerTail(tt.path, nil)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("NewScannerTail() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -504,10 +496,80 @@ func TestNewScannerJournaldValidation(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := NewScannerJournald(tt.unit)
+			_, err := NewScannerJournald(JournalFilter{Unit: tt.unit}, nil)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("NewScannerJournald() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
+
+type memPositions map[string]storage.Position
+
+func (m memPositions) Load(source string) (storage.Position, bool, error) {
+	pos, ok := m[source]
+	return pos, ok, nil
+}
+
+func (m memPositions) Save(pos storage.Position) error {
+	m[pos.Source] = pos
+	return nil
+}
+
+func TestScannerTailResumesAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	if err := os.WriteFile(path, []byte("before first start\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	positions := memPositions{}
+
+	appendLine := func(line string) {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(line + "\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	receive := func(s *Scanner, want string) {
+		t.Helper()
+		select {
+		case event := <-s.Events():
+			if event.Data != want {
+				t.Fatalf("got %q, want %q", event.Data, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for %q", want)
+		}
+	}
+
+	first, err := NewScannerTail(path, positions)
+	if err != nil {
+		t.Fatalf("NewScannerTail() error = %v", err)
+	}
+	first.Start()
+	appendLine("while running")
+	receive(first, "while running")
+	first.Stop()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := positions["file:"+path]
+	if saved.Offset != info.Size() || saved.Inode != inodeOf(info) {
+		t.Fatalf("saved position = %+v, want offset %d", saved, info.Size())
+	}
+
+	appendLine("while stopped")
+
+	second, err := NewScannerTail(path, positions)
+	if err != nil {
+		t.Fatalf("NewScannerTail() error = %v", err)
+	}
+	second.Start()
+	defer second.Stop()
+	receive(second, "while stopped")
+}
@@ -0,0 +1,64 @@
+package parser
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/d3m0k1d/BanForge/internal/storage"
+)
+
+// Parser turns scanner events into log entries for the judge. Parse returns
+// once eventCh is closed.
+type Parser interface {
+	Parse(eventCh <-chan Event, resultCh chan<- *storage.LogEntry)
+}
+
+// factory creates a parser whose entries belong to service.
+type factory func(service string) Parser
+
+// registry maps the names accepted by the parser field of a [[service]] to
+// the built-in parsers.
+var registry = map[string]factory{
+	"nginx": func(service string) Parser {
+		p := NewNginxParser()
+		p.service = service
+		return p
+	},
+	"apache": func(service string) Parser {
+		p := NewApacheParser()
+		p.service = service
+		return p
+	},
+	"ssh": func(service string) Parser {
+		p := NewSshdParser()
+		p.service = service
+		return p
+	},
+	"postfix":    newPackParser(postfixPatterns),
+	"dovecot":    newPackParser(dovecotPatterns),
+	"vsftpd":     newPackParser(vsftpdPatterns),
+	"proftpd":    newPackParser(proftpdPatterns),
+	"mysql":      newPackParser(mysqlPatterns),
+	"mariadb":    newPackParser(mysqlPatterns),
+	"postgresql": newPackParser(postgresqlPatterns),
+	"gitea":      newPackParser(giteaPatterns),
+	"grafana":    newPackParser(grafanaPatterns),
+	"openvpn":    newPackParser(openvpnPatterns),
+}
+
+// New returns the parser registered as name. Its log entries carry service
+// as their service name, which is what rules refer to.
+func New(name, service string) (Parser, error) {
+	newParser, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown parser %q, want one of %s", name, strings.Join(Names(), ", "))
+	}
+	return newParser(service), nil
+}
+
+// Names returns the registered parser names in sorted order.
+func Names() []string {
+	return slices.Sorted(maps.Keys(registry))
+}
@@ -0,0 +1,38 @@
+package parser
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	line := `203.0.113.5 - - [17/Oct/2026:10:00:00 +0000] "GET /wp-login.php HTTP/1.1" 404 153 "-" "curl/8.0"`
+
+	p, err := New("nginx", "blog")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	got := parseLine(t, p.Parse, line)
+	if got == nil {
+		t.Fatal("Parse() produced no entry")
+	}
+	if got.Service != "blog" {
+		t.Errorf("Service = %q, want %q", got.Service, "blog")
+	}
+
+	if _, err := New("nginxx", "blog"); err == nil {
+		t.Error("New() expected error for unknown parser")
+	}
+}
+
+func TestNames(t *testing.T) {
+	names := Names()
+	for _, want := range []string{"apache", "nginx", "ssh"} {
+		if !slices.Contains(names, want) {
+			t.Errorf("Names() = %v, missing %q", names, want)
+		}
+	}
+	if !slices.IsSorted(names) {
+		t.Errorf("Names() = %v, want sorted", names)
+	}
+}
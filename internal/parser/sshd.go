@@ -2,52 +2,106 @@ package parser
 
 import (
 	"regexp"
+	"time"
 
 	"github.com/d3m0k1d/BanForge/internal/logger"
 	"github.com/d3m0k1d/BanForge/internal/metrics"
 	"github.com/d3m0k1d/BanForge/internal/storage"
 )
 
+// sshd event types, reported as the entry status so rules can pick them with
+// Rule.Status. Failed authentications keep the status "Failed".
+const (
+	StatusInvalidUser       = "InvalidUser"
+	StatusMaxAuthTries      = "MaxAuthTries"
+	StatusAuthClosed        = "AuthClosed"
+	StatusPreauthDisconnect = "PreauthDisconnect"
+	StatusNoIdentification  = "NoIdentification"
+	StatusBannerExchange    = "BannerExchange"
+)
+
+var sshdPatterns = []packPattern{
+	// Failed password for invalid user admin from 203.0.113.7 port 50122 ssh2
+	{`^Failed\s+(?P<method>\w+)\s+for\s+(?:invalid\s+user\s+)?(?P<user>\S+)\s+from\s+` + ipGroup + `\s+port\s+\d+`, StatusFailed},
+	// Invalid user admin from 203.0.113.7 port 50122
+	{`^Invalid user (?P<user>\S*) from ` + ipGroup + `(?: port \d+)?`, StatusInvalidUser},
+	// error: maximum authentication attempts exceeded for root from 203.0.113.7 port 50122 ssh2 [preauth]
+	{`^error: maximum authentication attempts exceeded for (?:invalid user )?(?P<user>\S+) from ` + ipGroup + ` port \d+`, StatusMaxAuthTries},
+	// Disconnecting authenticating user root 203.0.113.7 port 50122: Too many authentication failures [preauth]
+	{`^Disconnecting (?:authenticating|invalid) user (?P<user>\S+) ` + ipGroup + ` port \d+: Too many authentication failures`, StatusMaxAuthTries},
+	// Connection closed by authenticating user root 203.0.113.7 port 50122 [preauth]
+	{`^Connection (?:closed|reset) by (?:authenticating|invalid) user (?P<user>\S*) ` + ipGroup + ` port \d+ \[preauth\]`, StatusAuthClosed},
+	// Disconnected from invalid user admin 203.0.113.7 port 50122 [preauth]
+	{`^Disconnected from (?:(?:authenticating|invalid) user (?P<user>\S*) )?` + ipGroup + ` port \d+ \[preauth\]`, StatusPreauthDisconnect},
+	// Received disconnect from 203.0.113.7 port 50122:11: Bye Bye [preauth]
+	{`^Received disconnect from ` + ipGroup + ` port \d+:\d+: .*\[preauth\]`, StatusPreauthDisconnect},
+	// Connection closed by 203.0.113.7 port 50122 [preauth]
+	{`^Connection (?:closed|reset) by ` + ipGroup + ` port \d+ \[preauth\]`, StatusPreauthDisconnect},
+	// Did not receive identification string from 203.0.113.7 port 50122
+	{`^Did not receive identification string from ` + ipGroup, StatusNoIdentification},
+	// banner exchange: Connection from 203.0.113.7 port 50122: invalid format
+	{`^banner exchange: Connection from ` + ipGroup + ` port \d+: `, StatusBannerExchange},
+}
+
+var compiledSshdPatterns = compilePatterns(sshdPatterns)
+
 type SshdParser struct {
-	pattern *regexp.Regexp
-	logger  *logger.Logger
+	service  string
+	header   *regexp.Regexp
+	patterns []linePattern
+	logger   *logger.Logger
 }
 
 func NewSshdParser() *SshdParser {
-	pattern := regexp.MustCompile(
-		`^([A-Za-z]{3}\s+\d{1,2}\s+\d{2}:\d{2}:\d{2})\s+(\S+)\s+sshd(?:-session)?\[(\d+)\]:\s+Failed\s+(\w+)\s+for\s+(?:invalid\s+user\s+)?(\S+)\s+from\s+(\S+)\s+port\s+(\d+)`,
+	// Syslog lines from files carry a header; journald events are matched on
+	// MESSAGE alone, so they do not depend on the header format.
+	header := regexp.MustCompile(
+		`^([A-Za-z]{3}\s+\d{1,2}\s+\d{2}:\d{2}:\d{2})\s+(\S+)\s+sshd(?:-session)?\[(\d+)\]:\s+(.*)$`,
 	)
 	return &SshdParser{
-		pattern: pattern,
-		logger:  logger.New(false),
+		service:  "ssh",
+		header:   header,
+		patterns: compiledSshdPatterns,
+		logger:   logger.New(false),
 	}
 }
 
+// message returns the sshd message of event, or false if it is a syslog line
+// from another program.
+func (p *SshdParser) message(event Event) (string, bool) {
+	if event.Fields != nil {
+		return event.Message(), true
+	}
+	matches := p.header.FindStringSubmatch(event.Data)
+	if matches == nil {
+		return "", false
+	}
+	return matches[4], true
+}
+
 func (p *SshdParser) Parse(eventCh <-chan Event, resultCh chan<- *storage.LogEntry) {
-	// Group 1: Timestamp, Group 2: hostame, Group 3: pid, Group 4: Method auth, Group 5: User, Group 6: IP, Group 7: port
 	for event := range eventCh {
-		matches := p.pattern.FindStringSubmatch(event.Data)
-		if matches == nil {
+		message, ok := p.message(event)
+		if !ok {
 			continue
 		}
-		resultCh <- &storage.LogEntry{
-			Service: "ssh",
-			IP:      matches[6],
-			Path:    matches[5], // user
-			Status:  "Failed",
-			Method:  matches[4], // method auth
+		entry := matchLine(p.patterns, p.service, message, p.logger)
+		if entry == nil {
+			continue
 		}
-		metrics.IncParserEvent("ssh")
+		entry.Time = event.Time(time.Now())
+		resultCh <- entry
+		metrics.IncParserEvent(p.service)
 		p.logger.Info(
 			"Parsed ssh log entry",
 			"ip",
-			matches[6],
+			entry.IP,
 			"user",
-			matches[5],
+			entry.Path,
 			"method",
-			matches[4],
+			entry.Method,
 			"status",
-			"Failed",
+			entry.Status,
 		)
 	}
 }
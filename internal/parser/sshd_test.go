@@ -0,0 +1,178 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/d3m0k1d/BanForge/internal/storage"
+)
+
+func TestSshdParser(t *testing.T) {
+	want := &storage.LogEntry{
+		Service: "ssh",
+		IP:      "203.0.113.7",
+		Path:    "root",
+		Status:  "Failed",
+		Method:  "password",
+	}
+
+	tests := []struct {
+		name  string
+		event Event
+		want  *storage.LogEntry
+	}{
+		{
+			name:  "syslog line",
+			event: Event{Data: "Mar  7 10:04:05 web1 sshd[812]: Failed password for root from 203.0.113.7 port 50122 ssh2"},
+			want:  want,
+		},
+		{
+			name:  "sshd-session",
+			event: Event{Data: "Mar 07 10:04:05 web1 sshd-session[812]: Failed password for invalid user root from 203.0.113.7 port 50122 ssh2"},
+			want:  want,
+		},
+		{
+			name:  "other program",
+			event: Event{Data: "Mar 07 10:04:05 web1 su[812]: Failed password for root from 203.0.113.7 port 50122 ssh2"},
+		},
+		{
+			name: "journald",
+			event: Event{
+				Data: "ignored",
+				Fields: map[string]string{
+					"SYSLOG_IDENTIFIER": "sshd",
+					"MESSAGE":           "Failed password for root from 203.0.113.7 port 50122 ssh2",
+				},
+			},
+			want: want,
+		},
+		{
+			name:  "accepted",
+			event: Event{Data: "Mar 07 10:04:05 web1 sshd[812]: Accepted publickey for root from 203.0.113.7 port 50122 ssh2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseEvent(t, NewSshdParser().Parse, tt.event)
+			if tt.want == nil {
+				if got != nil {
+					t.Fatalf("Parse() = %+v, want no entry", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("Parse() produced no entry")
+			}
+			if !sameEntry(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSshdParserEvents(t *testing.T) {
+	tests := []struct {
+		name       string
+		message    string
+		wantStatus string
+		wantUser   string
+	}{
+		{
+			name:       "failed publickey",
+			message:    "Failed publickey for git from 203.0.113.7 port 50122 ssh2: RSA SHA256:abc",
+			wantStatus: "Failed",
+			wantUser:   "git",
+		},
+		{
+			name:       "invalid user",
+			message:    "Invalid user oracle from 203.0.113.7 port 50122",
+			wantStatus: StatusInvalidUser,
+			wantUser:   "oracle",
+		},
+		{
+			name:       "invalid empty user",
+			message:    "Invalid user  from 203.0.113.7 port 50122",
+			wantStatus: StatusInvalidUser,
+		},
+		{
+			name:       "max auth tries",
+			message:    "error: maximum authentication attempts exceeded for invalid user admin from 203.0.113.7 port 50122 ssh2 [preauth]",
+			wantStatus: StatusMaxAuthTries,
+			wantUser:   "admin",
+		},
+		{
+			name:       "too many authentication failures",
+			message:    "Disconnecting authenticating user root 203.0.113.7 port 50122: Too many authentication failures [preauth]",
+			wantStatus: StatusMaxAuthTries,
+			wantUser:   "root",
+		},
+		{
+			name:       "connection closed by authenticating user",
+			message:    "Connection closed by authenticating user root 203.0.113.7 port 50122 [preauth]",
+			wantStatus: StatusAuthClosed,
+			wantUser:   "root",
+		},
+		{
+			name:       "connection reset by invalid user",
+			message:    "Connection reset by invalid user test 2001:db8::7 port 50122 [preauth]",
+			wantStatus: StatusAuthClosed,
+			wantUser:   "test",
+		},
+		{
+			name:       "disconnected from invalid user",
+			message:    "Disconnected from invalid user admin 203.0.113.7 port 50122 [preauth]",
+			wantStatus: StatusPreauthDisconnect,
+			wantUser:   "admin",
+		},
+		{
+			name:       "received disconnect",
+			message:    "Received disconnect from 203.0.113.7 port 50122:11: Bye Bye [preauth]",
+			wantStatus: StatusPreauthDisconnect,
+		},
+		{
+			name:       "connection closed preauth",
+			message:    "Connection closed by 203.0.113.7 port 50122 [preauth]",
+			wantStatus: StatusPreauthDisconnect,
+		},
+		{
+			name:       "no identification",
+			message:    "Did not receive identification string from 203.0.113.7 port 50122",
+			wantStatus: StatusNoIdentification,
+		},
+		{
+			name:       "banner exchange",
+			message:    "banner exchange: Connection from 203.0.113.7 port 50122: invalid format",
+			wantStatus: StatusBannerExchange,
+		},
+		{
+			name:    "disconnect after login",
+			message: "Disconnected from user bob 203.0.113.7 port 50122",
+		},
+		{
+			name:    "hostname instead of address",
+			message: "Invalid user admin from scanner.example.net port 50122",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := "Mar  7 10:04:05 web1 sshd[812]: " + tt.message
+			got := parseLine(t, NewSshdParser().Parse, line)
+			if tt.wantStatus == "" {
+				if got != nil {
+					t.Fatalf("Parse() = %+v, want no entry", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("Parse() produced no entry")
+			}
+			if got.Status != tt.wantStatus || got.Path != tt.wantUser {
+				t.Errorf("Parse() status, user = %q, %q, want %q, %q", got.Status, got.Path, tt.wantStatus, tt.wantUser)
+			}
+			if got.IP != "203.0.113.7" && got.IP != "2001:db8::7" {
+				t.Errorf("Parse() IP = %q", got.IP)
+			}
+		})
+	}
+}
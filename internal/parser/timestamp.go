@@ -0,0 +1,81 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// clfLayout is the common log format timestamp of nginx and apache.
+	clfLayout = "02/Jan/2006:15:04:05 -0700"
+	// syslogLayout is the BSD syslog timestamp, which has no year.
+	syslogLayout = "Jan _2 15:04:05"
+)
+
+// timeLayouts are tried in order by parseTime.
+var timeLayouts = []string{
+	clfLayout,
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999-0700",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999 MST",
+	"2006/01/02 15:04:05",
+	"Mon Jan _2 15:04:05 2006",
+	syslogLayout,
+}
+
+// parseTime parses a log timestamp in one of timeLayouts. Timestamps without
+// a zone are local time. A syslog timestamp gets the year that puts it
+// closest before now, so December lines read in January land in the old year.
+func parseTime(s string, now time.Time) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range timeLayouts {
+		t, err := time.ParseInLocation(layout, s, time.Local)
+		if err != nil {
+			continue
+		}
+		if layout == syslogLayout {
+			t = withYear(t, now)
+		}
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+func withYear(t, now time.Time) time.Time {
+	t = time.Date(now.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	// Allow for a little clock skew before assuming the previous year.
+	if t.After(now.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t
+}
+
+// leadingTime parses the timestamp a log line starts with: a syslog header
+// ("Mar  7 10:04:05 host ..."), an RFC 3339 first field as written by
+// rsyslog's high-precision format, or a date and time as the first two fields.
+func leadingTime(line string, now time.Time) (time.Time, bool) {
+	if len(line) >= len(syslogLayout) {
+		if t, err := time.ParseInLocation(syslogLayout, line[:len(syslogLayout)], time.Local); err == nil {
+			return withYear(t, now), true
+		}
+	}
+	fields := strings.SplitN(line, " ", 3)
+	if t, ok := parseTime(fields[0], now); ok {
+		return t, true
+	}
+	if len(fields) >= 2 {
+		return parseTime(fields[0]+" "+fields[1], now)
+	}
+	return time.Time{}, false
+}
+
+// journalTime returns the __REALTIME_TIMESTAMP of a journald event.
+func journalTime(fields map[string]string) (time.Time, bool) {
+	usec, err := strconv.ParseInt(fields["__REALTIME_TIMESTAMP"], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.UnixMicro(usec), true
+}
@@ -0,0 +1,195 @@
+package parser
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/d3m0k1d/BanForge/internal/storage"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2026, time.March, 8, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		name  string
+		input string
+		want  time.Time
+	}{
+		{
+			name:  "common log format",
+			input: "07/Mar/2026:10:04:05 +0100",
+			want:  time.Date(2026, time.March, 7, 9, 4, 5, 0, time.UTC),
+		},
+		{
+			name:  "rfc 3339",
+			input: "2026-03-07T10:04:05.123456Z",
+			want:  time.Date(2026, time.March, 7, 10, 4, 5, 123456000, time.UTC),
+		},
+		{
+			name:  "offset without colon",
+			input: "2026-03-07T10:04:05+0000",
+			want:  time.Date(2026, time.March, 7, 10, 4, 5, 0, time.UTC),
+		},
+		{
+			name:  "date and time without zone",
+			input: "2026-03-07 10:04:05",
+			want:  time.Date(2026, time.March, 7, 10, 4, 5, 0, time.Local),
+		},
+		{
+			name:  "slashed date",
+			input: "2026/03/07 10:04:05",
+			want:  time.Date(2026, time.March, 7, 10, 4, 5, 0, time.Local),
+		},
+		{
+			name:  "syslog",
+			input: "Mar  7 10:04:05",
+			want:  time.Date(2026, time.March, 7, 10, 4, 5, 0, time.Local),
+		},
+		{
+			name:  "syslog with zero-padded day",
+			input: "Mar 07 10:04:05",
+			want:  time.Date(2026, time.March, 7, 10, 4, 5, 0, time.Local),
+		},
+		{
+			name:  "syslog from last year",
+			input: "Dec 31 23:59:59",
+			want:  time.Date(2025, time.December, 31, 23, 59, 59, 0, time.Local),
+		},
+		{
+			name:  "garbage",
+			input: "yesterday",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseTime(tt.input, now)
+			if ok != !tt.want.IsZero() {
+				t.Fatalf("parseTime(%q) ok = %v", tt.input, ok)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseTime(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventTime(t *testing.T) {
+	now := time.Date(2026, time.March, 8, 12, 0, 0, 0, time.Local)
+	want := time.Date(2026, time.March, 7, 10, 4, 5, 0, time.Local)
+	tests := []struct {
+		name  string
+		event Event
+		want  time.Time
+	}{
+		{
+			name:  "syslog line",
+			event: Event{Data: "Mar  7 10:04:05 mx postfix/smtpd[1201]: connect from unknown[198.51.100.23]"},
+			want:  want,
+		},
+		{
+			name:  "rsyslog high precision",
+			event: Event{Data: "2026-03-07T10:04:05+00:00 mx postfix/smtpd[1201]: connect"},
+			want:  time.Date(2026, time.March, 7, 10, 4, 5, 0, time.UTC),
+		},
+		{
+			name:  "date and time fields",
+			event: Event{Data: "2026-03-07 10:04:05 31 [Warning] Access denied"},
+			want:  want,
+		},
+		{
+			name: "journald",
+			event: Event{
+				Data: "Mar 01 00:00:00 web1 sshd[812]: ignored",
+				Fields: map[string]string{
+					"__REALTIME_TIMESTAMP": strconv.FormatInt(want.UnixMicro(), 10),
+					"MESSAGE":              "Invalid user admin from 203.0.113.7",
+				},
+			},
+			want: want,
+		},
+		{
+			name:  "no timestamp",
+			event: Event{Data: "warning: unknown[198.51.100.23]: SASL LOGIN authentication failed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.event.Time(now); !got.Equal(tt.want) {
+				t.Errorf("Time() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParserEntryTime(t *testing.T) {
+	clf := time.Date(2026, time.March, 7, 10, 4, 5, 0, time.UTC)
+	syslog := time.Date(time.Now().Year(), time.March, 7, 10, 4, 5, 0, time.Local)
+	if syslog.After(time.Now()) {
+		syslog = syslog.AddDate(-1, 0, 0)
+	}
+	regex, err := NewRegexParser("app", `^(?P<time>\S+) login failed from (?P<ip>\S+)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	postfix, err := New("postfix", "postfix")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		parse func(<-chan Event, chan<- *storage.LogEntry)
+		line  string
+		want  time.Time
+	}{
+		{
+			name:  "nginx",
+			parse: NewNginxParser().Parse,
+			line:  `203.0.113.5 - - [07/Mar/2026:10:04:05 +0000] "GET / HTTP/1.1" 404 153`,
+			want:  clf,
+		},
+		{
+			name:  "apache",
+			parse: NewApacheParser().Parse,
+			line:  `203.0.113.5 - - [07/Mar/2026:11:04:05 +0100] "GET / HTTP/1.1" 404 153 "-" "-"`,
+			want:  clf,
+		},
+		{
+			name:  "ssh",
+			parse: NewSshdParser().Parse,
+			line:  "Mar  7 10:04:05 web1 sshd[812]: Invalid user admin from 203.0.113.7 port 50122",
+			want:  syslog,
+		},
+		{
+			name:  "pack parser",
+			parse: postfix.Parse,
+			line:  "Mar  7 10:04:05 mx postfix/smtpd[1201]: warning: unknown[198.51.100.23]: SASL LOGIN authentication failed: UGFzc3dvcmQ6",
+			want:  syslog,
+		},
+		{
+			name:  "time group",
+			parse: regex.Parse,
+			line:  "2026-03-07T10:04:05Z login failed from 203.0.113.5",
+			want:  time.Date(2026, time.March, 7, 10, 4, 5, 0, time.UTC),
+		},
+		{
+			name:  "unparsable time group",
+			parse: regex.Parse,
+			line:  "soon login failed from 203.0.113.5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseLine(t, tt.parse, tt.line)
+			if got == nil {
+				t.Fatal("Parse() produced no entry")
+			}
+			if !got.Time.Equal(tt.want) {
+				t.Errorf("Time = %v, want %v", got.Time, tt.want)
+			}
+		})
+	}
+}
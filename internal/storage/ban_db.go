@@ -2,7 +2,9 @@ package storage
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"net/netip"
 	"os"
 	"time"
 
@@ -17,12 +19,17 @@ import (
 type BanWriter struct {
 	logger *logger.Logger
 	db     *sql.DB
+	now    func() time.Time
 }
 
 func NewBanWriter() (*BanWriter, error) {
+	return NewBanWriterWithDBPath(banDBPath)
+}
+
+func NewBanWriterWithDBPath(dbPath string) (*BanWriter, error) {
 	db, err := sql.Open(
 		"sqlite",
-		buildSqliteDsn(banDBPath, pragmas),
+		buildSqliteDsn(dbPath, pragmas),
 	)
 	if err != nil {
 		return nil, err
@@ -30,9 +37,34 @@ func NewBanWriter() (*BanWriter, error) {
 	return &BanWriter{
 		logger: logger.New(false),
 		db:     db,
+		now:    time.Now,
 	}, nil
 }
 
+// NewMemoryBanDB returns a writer and a reader sharing a private in-memory
+// ban database, for offline runs that must not touch bans.db.
+func NewMemoryBanDB() (*BanWriter, *BanReader, error) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		return nil, nil, err
+	}
+	// Every connection to :memory: is a database of its own.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(CreateBansTable); err != nil {
+		_ = db.Close()
+		return nil, nil, fmt.Errorf("failed to create table: %w", err)
+	}
+	w := &BanWriter{logger: logger.New(false), db: db, now: time.Now}
+	r := &BanReader{logger: logger.New(false), db: db}
+	return w, r, nil
+}
+
+// SetClock replaces the time source for ban, unban and expiry times, so a
+// replay of old logs can ban and expire as of the time of each line.
+func (d *BanWriter) SetClock(now func() time.Time) {
+	d.now = now
+}
+
 func (d *BanWriter) CreateTable() error {
 	_, err := d.db.Exec(CreateBansTable)
 	if err != nil {
@@ -43,6 +75,7 @@ func (d *BanWriter) CreateTable() error {
 	return nil
 }
 
+// AddBan records a manual ban of ip for ttl.
 func (d *BanWriter) AddBan(ip string, ttl string, reason string) error {
 	duration, err := config.ParseDurationWithYears(ttl)
 	if err != nil {
@@ -50,12 +83,52 @@ func (d *BanWriter) AddBan(ip string, ttl string, reason string) error {
 		metrics.IncError()
 		return fmt.Errorf("invalid duration: %w", err)
 	}
+	return d.AddBanFor(ip, duration, reason, SourceManual)
+}
+
+// AddBanFor bans ip for duration and appends the ban to ban_history, which
+// is kept after the ban itself expires.
+func (d *BanWriter) AddBanFor(
+	ip string,
+	duration time.Duration,
+	reason string,
+	source string,
+) error {
+	return d.addBan("bans", ip, duration, reason, source)
+}
+
+// AddMonitorBan records the ban a rule in monitor mode would have made. It
+// goes to monitor_bans, so it is never restored to the firewall, and to
+// ban_history with source monitor.
+func (d *BanWriter) AddMonitorBan(ip string, duration time.Duration, reason string) error {
+	return d.addBan("monitor_bans", ip, duration, reason, SourceMonitor)
+}
 
-	now := time.Now()
+func (d *BanWriter) addBan(
+	table string,
+	ip string,
+	duration time.Duration,
+	reason string,
+	source string,
+) (err error) {
+	now := d.now()
 	expiredAt := now.Add(duration)
 
-	_, err = d.db.Exec(
-		"INSERT INTO bans (ip, reason, banned_at, expired_at) VALUES (?, ?, ?, ?)",
+	tx, err := d.db.Begin()
+	if err != nil {
+		metrics.IncError()
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil &&
+			!errors.Is(rollbackErr, sql.ErrTxDone) {
+			err = errors.Join(err, fmt.Errorf("failed to rollback transaction: %w", rollbackErr))
+		}
+	}()
+
+	_, err = tx.Exec(
+		// #nosec G202 - table is one of two constants
+		"INSERT INTO "+table+" (ip, reason, banned_at, expired_at) VALUES (?, ?, ?, ?)",
 		ip,
 		reason,
 		now.Format(time.RFC3339),
@@ -67,26 +140,117 @@ func (d *BanWriter) AddBan(ip string, ttl string, reason string) error {
 		return err
 	}
 
-	metrics.IncDBOperation("insert", "bans")
+	_, err = tx.Exec(
+		`INSERT INTO ban_history (ip, event, rule, source, created_at, expired_at)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		ip,
+		EventBan,
+		reason,
+		source,
+		now.Format(time.RFC3339),
+		expiredAt.Format(time.RFC3339),
+	)
+	if err != nil {
+		d.logger.Error("Failed to record ban history", "error", err)
+		metrics.IncError()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		metrics.IncError()
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	metrics.IncDBOperation("insert", table)
+	metrics.IncDBOperation("insert", "ban_history")
 	return nil
 }
 
-func (d *BanWriter) RemoveBan(ip string) error {
-	_, err := d.db.Exec("DELETE FROM bans WHERE ip = ?", ip)
+// RecordRestore adds a restore event for an active ban that was re-applied
+// to the firewall on daemon startup.
+func (d *BanWriter) RecordRestore(ip string) error {
+	_, err := d.db.Exec(
+		`INSERT INTO ban_history (ip, event, rule, source, created_at, expired_at)
+		 SELECT ip, ?, reason, ?, ?, expired_at FROM bans WHERE ip = ?`,
+		EventBan,
+		SourceRestore,
+		d.now().Format(time.RFC3339),
+		ip,
+	)
+	if err != nil {
+		d.logger.Error("Failed to record ban restore", "error", err)
+		metrics.IncError()
+		return err
+	}
+	metrics.IncDBOperation("insert", "ban_history")
+	return nil
+}
+
+// RemoveBan lifts a ban by hand and records the unban in ban_history. An ip
+// that is not banned leaves both tables untouched.
+func (d *BanWriter) RemoveBan(ip string) (err error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		metrics.IncError()
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil &&
+			!errors.Is(rollbackErr, sql.ErrTxDone) {
+			err = errors.Join(err, fmt.Errorf("failed to rollback transaction: %w", rollbackErr))
+		}
+	}()
+
+	_, err = tx.Exec(
+		`INSERT INTO ban_history (ip, event, rule, source, created_at, expired_at)
+		 SELECT ip, ?, reason, ?, ?, expired_at FROM bans WHERE ip = ?`,
+		EventUnban,
+		SourceManual,
+		d.now().Format(time.RFC3339),
+		ip,
+	)
+	if err != nil {
+		d.logger.Error("Failed to record unban", "error", err)
+		metrics.IncError()
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM bans WHERE ip = ?", ip)
 	if err != nil {
 		d.logger.Error("Failed to remove ban", "error", err)
 		metrics.IncError()
 		return err
 	}
+
+	if err := tx.Commit(); err != nil {
+		metrics.IncError()
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	metrics.IncDBOperation("insert", "ban_history")
 	metrics.IncDBOperation("delete", "bans")
 	return nil
 }
 
-func (w *BanWriter) RemoveExpiredBans() ([]string, error) {
-	var ips []string
-	now := time.Now().Format(time.RFC3339)
+// RemoveExpiredBans lifts the bans and monitor bans that have expired,
+// records them in ban_history and returns the expired bans, which still
+// have to be lifted at the firewall.
+func (w *BanWriter) RemoveExpiredBans() (ips []string, err error) {
+	now := w.now().Format(time.RFC3339)
+
+	tx, err := w.db.Begin()
+	if err != nil {
+		metrics.IncError()
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil &&
+			!errors.Is(rollbackErr, sql.ErrTxDone) {
+			err = errors.Join(err, fmt.Errorf("failed to rollback transaction: %w", rollbackErr))
+		}
+	}()
 
-	rows, err := w.db.Query(
+	rows, err := tx.Query(
 		"SELECT ip FROM bans WHERE expired_at < ?",
 		now,
 	)
@@ -115,7 +279,21 @@ func (w *BanWriter) RemoveExpiredBans() ([]string, error) {
 		return nil, err
 	}
 
-	result, err := w.db.Exec(
+	_, err = tx.Exec(
+		`INSERT INTO ban_history (ip, event, rule, source, created_at, expired_at)
+		 SELECT ip, ?, reason, ?, ?, expired_at FROM bans WHERE expired_at < ?`,
+		EventUnban,
+		SourceJudge,
+		now,
+		now,
+	)
+	if err != nil {
+		w.logger.Error("Failed to record expired bans", "error", err)
+		metrics.IncError()
+		return nil, err
+	}
+
+	result, err := tx.Exec(
 		"DELETE FROM bans WHERE expired_at < ?",
 		now,
 	)
@@ -130,14 +308,83 @@ func (w *BanWriter) RemoveExpiredBans() ([]string, error) {
 		return nil, err
 	}
 
+	monitorRemoved, err := w.removeExpiredMonitorBans(tx, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		metrics.IncError()
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	if rowsAffected > 0 {
 		w.logger.Info("Removed expired bans", "count", rowsAffected, "ips", len(ips))
 		metrics.IncDBOperation("delete_expired", "bans")
 	}
-
+	if monitorRemoved > 0 {
+		metrics.IncDBOperation("delete_expired", "monitor_bans")
+	}
 	return ips, nil
 }
 
+// RemoveExpiredMonitorBans lifts the monitor bans that have expired and
+// leaves the bans table alone. Monitor mode uses it, so bans applied while
+// enforcing stay on record for as long as they stay in the firewall.
+func (w *BanWriter) RemoveExpiredMonitorBans() (err error) {
+	tx, err := w.db.Begin()
+	if err != nil {
+		metrics.IncError()
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil &&
+			!errors.Is(rollbackErr, sql.ErrTxDone) {
+			err = errors.Join(err, fmt.Errorf("failed to rollback transaction: %w", rollbackErr))
+		}
+	}()
+
+	removed, err := w.removeExpiredMonitorBans(tx, w.now().Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		metrics.IncError()
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	if removed > 0 {
+		metrics.IncDBOperation("delete_expired", "monitor_bans")
+	}
+	return nil
+}
+
+// removeExpiredMonitorBans lifts monitor bans that ended before now and
+// returns how many it removed. There is nothing to unban at the firewall,
+// so only the history is updated.
+func (w *BanWriter) removeExpiredMonitorBans(tx *sql.Tx, now string) (int64, error) {
+	_, err := tx.Exec(
+		`INSERT INTO ban_history (ip, event, rule, source, created_at, expired_at)
+		 SELECT ip, ?, reason, ?, ?, expired_at FROM monitor_bans WHERE expired_at < ?`,
+		EventUnban,
+		SourceMonitor,
+		now,
+		now,
+	)
+	if err != nil {
+		w.logger.Error("Failed to record expired monitor bans", "error", err)
+		metrics.IncError()
+		return 0, err
+	}
+
+	result, err := tx.Exec("DELETE FROM monitor_bans WHERE expired_at < ?", now)
+	if err != nil {
+		w.logger.Error("Failed to remove expired monitor bans", "error", err)
+		metrics.IncError()
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 func (d *BanWriter) Close() error {
 	d.logger.Info("Closing database connection")
 	err := d.db.Close()
@@ -155,8 +402,12 @@ type BanReader struct {
 }
 
 func NewBanReader() (*BanReader, error) {
+	return NewBanReaderWithDBPath(banDBPath)
+}
+
+func NewBanReaderWithDBPath(dbPath string) (*BanReader, error) {
 	db, err := sql.Open("sqlite",
-		"/var/lib/banforge/bans.db?"+
+		dbPath+"?"+
 			"mode=ro&"+
 			"_pragma=journal_mode(WAL)&"+
 			"_pragma=mmap_size(268435456)&"+
@@ -172,18 +423,195 @@ func NewBanReader() (*BanReader, error) {
 	}, nil
 }
 
+// IsBanned reports whether ip has a ban of its own or lies inside a banned
+// CIDR prefix.
 func (d *BanReader) IsBanned(ip string) (bool, error) {
 	var bannedIP string
 	err := d.db.QueryRow("SELECT ip FROM bans WHERE ip = ? ", ip).Scan(&bannedIP)
-	if err == sql.ErrNoRows {
+	if err != nil && err != sql.ErrNoRows {
+		metrics.IncError()
+		return false, fmt.Errorf("failed to check ban status: %w", err)
+	}
+	metrics.IncDBOperation("select", "bans")
+	if err == nil {
+		return true, nil
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
 		return false, nil
 	}
+	prefixes, err := d.bannedTargets(true)
 	if err != nil {
-		metrics.IncError()
 		return false, fmt.Errorf("failed to check ban status: %w", err)
 	}
+	for _, target := range prefixes {
+		prefix, err := netip.ParsePrefix(target)
+		if err == nil && prefix.Contains(addr.Unmap()) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// IsMonitored reports whether ip has an unexpired monitor ban.
+func (d *BanReader) IsMonitored(ip string) (bool, error) {
+	var monitoredIP string
+	err := d.db.QueryRow("SELECT ip FROM monitor_bans WHERE ip = ?", ip).Scan(&monitoredIP)
+	if err != nil && err != sql.ErrNoRows {
+		metrics.IncError()
+		return false, fmt.Errorf("failed to check monitor ban status: %w", err)
+	}
+	metrics.IncDBOperation("select", "monitor_bans")
+	return err == nil, nil
+}
+
+// ActiveBansIn returns how many single addresses inside prefix are banned.
+func (d *BanReader) ActiveBansIn(prefix netip.Prefix) (int, error) {
+	ips, err := d.bannedTargets(false)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count bans in %s: %w", prefix, err)
+	}
+	count := 0
+	for _, ip := range ips {
+		addr, err := netip.ParseAddr(ip)
+		if err == nil && prefix.Contains(addr.Unmap()) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// bannedTargets lists banned CIDR prefixes, or banned single addresses when
+// prefixes is false.
+func (d *BanReader) bannedTargets(prefixes bool) ([]string, error) {
+	query := "SELECT ip FROM bans WHERE ip NOT LIKE '%/%'"
+	if prefixes {
+		query = "SELECT ip FROM bans WHERE ip LIKE '%/%'"
+	}
+	rows, err := d.db.Query(query)
+	if err != nil {
+		metrics.IncError()
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			d.logger.Error("Failed to close rows", "error", err)
+		}
+	}()
+
+	var targets []string
+	for rows.Next() {
+		var target string
+		if err := rows.Scan(&target); err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 	metrics.IncDBOperation("select", "bans")
-	return true, nil
+	return targets, nil
+}
+
+// BanCount returns how many times ip has been banned, including bans that
+// have already expired or were lifted. Restores on startup and monitor bans
+// are not counted.
+func (d *BanReader) BanCount(ip string) (int, error) {
+	var count int
+	err := d.db.QueryRow(
+		"SELECT COUNT(*) FROM ban_history WHERE ip = ? AND event = ? AND source NOT IN (?, ?)",
+		ip,
+		EventBan,
+		SourceRestore,
+		SourceMonitor,
+	).Scan(&count)
+	if err != nil {
+		metrics.IncError()
+		return 0, fmt.Errorf("failed to count bans: %w", err)
+	}
+	metrics.IncDBOperation("select", "ban_history")
+	return count, nil
+}
+
+// MonitorBanCount returns how many monitor bans ip has had.
+func (d *BanReader) MonitorBanCount(ip string) (int, error) {
+	var count int
+	err := d.db.QueryRow(
+		"SELECT COUNT(*) FROM ban_history WHERE ip = ? AND event = ? AND source = ?",
+		ip,
+		EventBan,
+		SourceMonitor,
+	).Scan(&count)
+	if err != nil {
+		metrics.IncError()
+		return 0, fmt.Errorf("failed to count monitor bans: %w", err)
+	}
+	metrics.IncDBOperation("select", "ban_history")
+	return count, nil
+}
+
+// HistoryFilter narrows down BanReader.History. Zero values match everything.
+type HistoryFilter struct {
+	IP    string
+	Rule  string
+	Since time.Time
+	Until time.Time
+}
+
+// History returns ban and unban events matching filter, oldest first.
+func (d *BanReader) History(filter HistoryFilter) ([]BanEvent, error) {
+	query := `SELECT id, ip, event, COALESCE(rule, ''), source, created_at,
+		COALESCE(expired_at, '') FROM ban_history WHERE 1 = 1`
+	var args []any
+	if filter.IP != "" {
+		query += " AND ip = ?"
+		args = append(args, filter.IP)
+	}
+	if filter.Rule != "" {
+		query += " AND rule = ?"
+		args = append(args, filter.Rule)
+	}
+	if !filter.Since.IsZero() {
+		query += " AND created_at >= ?"
+		args = append(args, filter.Since.Format(time.RFC3339))
+	}
+	if !filter.Until.IsZero() {
+		query += " AND created_at <= ?"
+		args = append(args, filter.Until.Format(time.RFC3339))
+	}
+	query += " ORDER BY created_at, id"
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		d.logger.Error("Failed to get ban history", "error", err)
+		metrics.IncError()
+		return nil, fmt.Errorf("failed to get ban history: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			d.logger.Error("Failed to close rows", "error", err)
+		}
+	}()
+
+	var events []BanEvent
+	for rows.Next() {
+		var e BanEvent
+		err := rows.Scan(&e.ID, &e.IP, &e.Event, &e.Rule, &e.Source, &e.CreatedAt, &e.Expired)
+		if err != nil {
+			metrics.IncError()
+			return nil, fmt.Errorf("failed to scan ban history: %w", err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		metrics.IncError()
+		return nil, fmt.Errorf("failed to iterate ban history: %w", err)
+	}
+
+	metrics.IncDBOperation("select", "ban_history")
+	return events, nil
 }
 
 func (d *BanReader) BanList() error {
@@ -218,10 +646,11 @@ func (d *BanReader) BanList() error {
 	return nil
 }
 
-func (d *BanReader) RestoreBans() ([]string, error) {
+// ActiveBans returns the bans that have not expired yet, with their expiry.
+func (d *BanReader) ActiveBans() ([]ActiveBan, error) {
 	now := time.Now().Format(time.RFC3339)
 	rows, err := d.db.Query(
-		"SELECT ip FROM bans WHERE expired_at > ?",
+		"SELECT ip, expired_at FROM bans WHERE expired_at > ?",
 		now,
 	)
 	if err != nil {
@@ -235,15 +664,21 @@ func (d *BanReader) RestoreBans() ([]string, error) {
 		}
 	}()
 
-	var ips []string
+	var bans []ActiveBan
 	for rows.Next() {
-		var ip string
-		if err := rows.Scan(&ip); err != nil {
+		var ban ActiveBan
+		var expiredAt string
+		if err := rows.Scan(&ban.IP, &expiredAt); err != nil {
 			d.logger.Error("Failed to scan active ban", "error", err)
 			metrics.IncError()
 			return nil, fmt.Errorf("failed to scan active ban: %w", err)
 		}
-		ips = append(ips, ip)
+		ban.ExpiresAt, err = time.Parse(time.RFC3339, expiredAt)
+		if err != nil {
+			metrics.IncError()
+			return nil, fmt.Errorf("active ban %s: invalid expired_at %q: %w", ban.IP, expiredAt, err)
+		}
+		bans = append(bans, ban)
 	}
 	if err := rows.Err(); err != nil {
 		d.logger.Error("Failed to iterate active bans", "error", err)
@@ -252,7 +687,7 @@ func (d *BanReader) RestoreBans() ([]string, error) {
 	}
 
 	metrics.IncDBOperation("select_active", "bans")
-	return ips, nil
+	return bans, nil
 }
 
 func (d *BanReader) Close() error {
@@ -1,11 +1,11 @@
 package storage
 
 import (
-	"database/sql"
-
-	"github.com/d3m0k1d/BanForge/internal/logger"
+	"net/netip"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestBanWriter_AddBan(t *testing.T) {
@@ -209,7 +209,7 @@ func TestBanReader_IsBanned(t *testing.T) {
 	}
 }
 
-func TestBanReader_RestoreBans(t *testing.T) {
+func TestBanReader_ActiveBans(t *testing.T) {
 	tempDir := t.TempDir()
 	dbPath := filepath.Join(tempDir, "bans_test.db")
 
@@ -235,15 +235,15 @@ func TestBanReader_RestoreBans(t *testing.T) {
 	}
 	defer reader.Close()
 
-	ips, err := reader.RestoreBans()
+	bans, err := reader.ActiveBans()
 	if err != nil {
-		t.Fatalf("RestoreBans failed: %v", err)
+		t.Fatalf("ActiveBans failed: %v", err)
 	}
-	if len(ips) != 1 {
-		t.Fatalf("RestoreBans returned %d IPs, want 1", len(ips))
+	if len(bans) != 1 || bans[0].IP != "192.0.2.10" {
+		t.Fatalf("ActiveBans returned %+v, want the active ban", bans)
 	}
-	if ips[0] != "192.0.2.10" {
-		t.Errorf("RestoreBans returned %q, want active IP", ips[0])
+	if left := time.Until(bans[0].ExpiresAt); left <= 59*time.Minute || left > time.Hour {
+		t.Errorf("ActiveBans expiry in %v, want about 1h", left)
 	}
 }
 
@@ -387,32 +387,351 @@ func TestRemoveNonExistentBan(t *testing.T) {
 	if err != nil {
 		t.Errorf("RemoveBan should not return error for non-existent ban: %v", err)
 	}
+
+	reader, err := NewBanReaderWithDBPath(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create BanReader: %v", err)
+	}
+	defer reader.Close()
+
+	events, err := reader.History(HistoryFilter{})
+	if err != nil {
+		t.Fatalf("History() error = %v", err)
+	}
+	if len(events) != 0 {
+		t.Errorf("History() = %+v, want no unban event for an IP that was not banned", events)
+	}
 }
-func NewBanWriterWithDBPath(dbPath string) (*BanWriter, error) {
-	db, err := sql.Open("sqlite", dbPath+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(30000)&_pragma=synchronous(NORMAL)")
+func TestBanHistorySurvivesExpiry(t *testing.T) {
+	tempDir := t.TempDir()
+	dbPath := filepath.Join(tempDir, "bans_test.db")
+
+	writer, err := NewBanWriterWithDBPath(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create BanWriter: %v", err)
+	}
+	defer writer.Close()
+
+	if err := writer.CreateTable(); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	ip := "192.0.2.20"
+	if err := writer.AddBan(ip, "-1h", "test"); err != nil {
+		t.Fatalf("Failed to add expired ban: %v", err)
+	}
+	if _, err := writer.RemoveExpiredBans(); err != nil {
+		t.Fatalf("RemoveExpiredBans failed: %v", err)
+	}
+	if err := writer.AddBanFor(ip, time.Hour, "test", SourceJudge); err != nil {
+		t.Fatalf("AddBanFor failed: %v", err)
+	}
+
+	reader, err := NewBanReaderWithDBPath(dbPath)
 	if err != nil {
-		return nil, err
+		t.Fatalf("Failed to create BanReader: %v", err)
+	}
+	defer reader.Close()
+
+	count, err := reader.BanCount(ip)
+	if err != nil {
+		t.Fatalf("BanCount failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("BanCount(%q) = %d, want 2", ip, count)
+	}
+
+	count, err = reader.BanCount("192.0.2.21")
+	if err != nil {
+		t.Fatalf("BanCount failed: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("BanCount for unknown IP = %d, want 0", count)
 	}
-	return &BanWriter{
-		logger: logger.New(false),
-		db:     db,
-	}, nil
 }
 
-func NewBanReaderWithDBPath(dbPath string) (*BanReader, error) {
-	db, err := sql.Open("sqlite",
-		dbPath+"?"+
-			"mode=ro&"+
-			"_pragma=journal_mode(WAL)&"+
-			"_pragma=mmap_size(268435456)&"+
-			"_pragma=cache_size(-2000)&"+
-			"_pragma=query_only(1)")
+func TestAddBanForDuplicateKeepsHistoryConsistent(t *testing.T) {
+	tempDir := t.TempDir()
+	dbPath := filepath.Join(tempDir, "bans_test.db")
+
+	writer, err := NewBanWriterWithDBPath(dbPath)
 	if err != nil {
-		return nil, err
+		t.Fatalf("Failed to create BanWriter: %v", err)
 	}
+	defer writer.Close()
+
+	if err := writer.CreateTable(); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	ip := "192.0.2.22"
+	if err := writer.AddBanFor(ip, time.Hour, "test", SourceJudge); err != nil {
+		t.Fatalf("AddBanFor failed: %v", err)
+	}
+	if err := writer.AddBanFor(ip, time.Hour, "test", SourceJudge); err == nil {
+		t.Fatal("AddBanFor expected error for already banned IP")
+	}
+
+	var count int
+	if err := writer.db.QueryRow("SELECT COUNT(*) FROM ban_history WHERE ip = ?", ip).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("ban_history rows = %d, want 1", count)
+	}
+}
 
-	return &BanReader{
-		logger: logger.New(false),
-		db:     db,
-	}, nil
+func TestBanReader_History(t *testing.T) {
+	tempDir := t.TempDir()
+	dbPath := filepath.Join(tempDir, "bans_test.db")
+
+	writer, err := NewBanWriterWithDBPath(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create BanWriter: %v", err)
+	}
+	defer writer.Close()
+
+	if err := writer.CreateTable(); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	if err := writer.AddBanFor("192.0.2.30", time.Hour, "wp-login", SourceJudge); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.RecordRestore("192.0.2.30"); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.RemoveBan("192.0.2.30"); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.AddBan("192.0.2.31", "-1h", "manual ban"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.RemoveExpiredBans(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := NewBanReaderWithDBPath(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create BanReader: %v", err)
+	}
+	defer reader.Close()
+
+	tests := []struct {
+		name   string
+		filter HistoryFilter
+		want   []string
+	}{
+		{
+			name:   "all events",
+			filter: HistoryFilter{},
+			want: []string{
+				"192.0.2.30 ban wp-login judge",
+				"192.0.2.30 ban wp-login restore",
+				"192.0.2.30 unban wp-login manual",
+				"192.0.2.31 ban manual ban manual",
+				"192.0.2.31 unban manual ban judge",
+			},
+		},
+		{
+			name:   "by IP",
+			filter: HistoryFilter{IP: "192.0.2.31"},
+			want: []string{
+				"192.0.2.31 ban manual ban manual",
+				"192.0.2.31 unban manual ban judge",
+			},
+		},
+		{
+			name:   "by rule",
+			filter: HistoryFilter{Rule: "wp-login"},
+			want: []string{
+				"192.0.2.30 ban wp-login judge",
+				"192.0.2.30 ban wp-login restore",
+				"192.0.2.30 unban wp-login manual",
+			},
+		},
+		{
+			name:   "time range in the past",
+			filter: HistoryFilter{Until: time.Now().Add(-time.Hour)},
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := reader.History(tt.filter)
+			if err != nil {
+				t.Fatalf("History() error = %v", err)
+			}
+			var got []string
+			for _, e := range events {
+				got = append(got, e.IP+" "+e.Event+" "+e.Rule+" "+e.Source)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("History() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("History()[%d] = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	count, err := reader.BanCount("192.0.2.30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("BanCount() = %d, want 1 (restores not counted)", count)
+	}
+}
+
+func TestBanReader_SubnetBans(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "bans_test.db")
+
+	writer, err := NewBanWriterWithDBPath(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create BanWriter: %v", err)
+	}
+	defer writer.Close()
+	if err := writer.CreateTable(); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	for _, ip := range []string{"192.0.2.1", "192.0.2.2", "198.51.100.1", "2001:db8::/64"} {
+		if err := writer.AddBan(ip, "1h", "test"); err != nil {
+			t.Fatalf("AddBan(%q) error = %v", ip, err)
+		}
+	}
+
+	reader, err := NewBanReaderWithDBPath(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create BanReader: %v", err)
+	}
+	defer reader.Close()
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "2001:db8::/64", want: true},
+		{ip: "2001:db8::abcd", want: true},
+		{ip: "2001:db8:0:1::1", want: false},
+		{ip: "192.0.2.3", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			got, err := reader.IsBanned(tt.ip)
+			if err != nil {
+				t.Fatalf("IsBanned() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("IsBanned(%q) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+
+	count, err := reader.ActiveBansIn(netip.MustParsePrefix("192.0.2.0/24"))
+	if err != nil {
+		t.Fatalf("ActiveBansIn() error = %v", err)
+	}
+	if count != 2 {
+		t.Errorf("ActiveBansIn() = %d, want 2", count)
+	}
+}
+
+func TestMemoryBanDBClock(t *testing.T) {
+	w, r, err := NewMemoryBanDB()
+	if err != nil {
+		t.Fatalf("NewMemoryBanDB() error = %v", err)
+	}
+	defer w.Close()
+
+	now := time.Date(2026, time.March, 7, 10, 4, 5, 0, time.Local)
+	w.SetClock(func() time.Time { return now })
+
+	if err := w.AddBanFor("192.0.2.1", time.Hour, "sshd", SourceJudge); err != nil {
+		t.Fatalf("AddBanFor() error = %v", err)
+	}
+	if banned, err := r.IsBanned("192.0.2.1"); err != nil || !banned {
+		t.Fatalf("IsBanned() = %v, %v, want true", banned, err)
+	}
+
+	now = now.Add(30 * time.Minute)
+	if ips, err := w.RemoveExpiredBans(); err != nil || len(ips) != 0 {
+		t.Fatalf("RemoveExpiredBans() = %v, %v, want nothing before expiry", ips, err)
+	}
+	now = now.Add(time.Hour)
+	if ips, err := w.RemoveExpiredBans(); err != nil || len(ips) != 1 {
+		t.Fatalf("RemoveExpiredBans() = %v, %v, want the expired ban", ips, err)
+	}
+	if banned, err := r.IsBanned("192.0.2.1"); err != nil || banned {
+		t.Fatalf("IsBanned() = %v, %v, want false after expiry", banned, err)
+	}
+
+	events, err := r.History(HistoryFilter{IP: "192.0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2026-03-07T10:04:05", "2026-03-07T11:34:05"}
+	if len(events) != 2 {
+		t.Fatalf("History() = %+v, want ban and unban", events)
+	}
+	for i, e := range events {
+		if !strings.HasPrefix(e.CreatedAt, want[i]) {
+			t.Errorf("event %d created_at = %s, want %s", i, e.CreatedAt, want[i])
+		}
+	}
+}
+
+func TestMonitorBans(t *testing.T) {
+	w, r, err := NewMemoryBanDB()
+	if err != nil {
+		t.Fatalf("NewMemoryBanDB() error = %v", err)
+	}
+	defer w.Close()
+
+	now := time.Date(2026, time.March, 7, 10, 4, 5, 0, time.Local)
+	w.SetClock(func() time.Time { return now })
+
+	if err := w.AddMonitorBan("192.0.2.1", time.Hour, "wp-login"); err != nil {
+		t.Fatalf("AddMonitorBan() error = %v", err)
+	}
+	if monitored, err := r.IsMonitored("192.0.2.1"); err != nil || !monitored {
+		t.Fatalf("IsMonitored() = %v, %v, want true", monitored, err)
+	}
+	if banned, err := r.IsBanned("192.0.2.1"); err != nil || banned {
+		t.Fatalf("IsBanned() = %v, %v, want false for a monitor ban", banned, err)
+	}
+	if bans, err := r.ActiveBans(); err != nil || len(bans) != 0 {
+		t.Fatalf("ActiveBans() = %v, %v, want no monitor bans", bans, err)
+	}
+	if count, err := r.BanCount("192.0.2.1"); err != nil || count != 0 {
+		t.Errorf("BanCount() = %d, %v, want 0", count, err)
+	}
+	if count, err := r.MonitorBanCount("192.0.2.1"); err != nil || count != 1 {
+		t.Errorf("MonitorBanCount() = %d, %v, want 1", count, err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if ips, err := w.RemoveExpiredBans(); err != nil || len(ips) != 0 {
+		t.Fatalf("RemoveExpiredBans() = %v, %v, want nothing to unban at the firewall", ips, err)
+	}
+	if monitored, err := r.IsMonitored("192.0.2.1"); err != nil || monitored {
+		t.Fatalf("IsMonitored() = %v, %v, want false after expiry", monitored, err)
+	}
+
+	events, err := r.History(HistoryFilter{IP: "192.0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("History() = %+v, want ban and unban", events)
+	}
+	for _, e := range events {
+		if e.Source != SourceMonitor || e.Rule != "wp-login" {
+			t.Errorf("event %+v, want source %s and rule wp-login", e, SourceMonitor)
+		}
+	}
 }
@@ -34,7 +34,7 @@ func buildSqliteDsn(path string, pragmas map[string]string) string {
 	return path + "?" + "mode=rwc&" + strings.Join(pragmastrs, "&")
 }
 
-func initDB(dsn, sqlstr string) (err error) {
+func initDB(dsn, sqlstr string, migrations []columnMigration, postsql string) (err error) {
 	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return fmt.Errorf("failed to open %q: %w", dsn, err)
@@ -49,13 +49,73 @@ func initDB(dsn, sqlstr string) (err error) {
 	if err != nil {
 		return fmt.Errorf("failed to create table: %w", err)
 	}
+	if err = migrate(db, migrations); err != nil {
+		return err
+	}
+	if postsql != "" {
+		if _, err = db.Exec(postsql); err != nil {
+			return fmt.Errorf("failed to create indexes: %w", err)
+		}
+	}
 	return err
 }
 
+func migrate(db *sql.DB, migrations []columnMigration) error {
+	for _, m := range migrations {
+		exists, err := columnExists(db, m.table, m.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		// #nosec G202 - table, column and definition come from requestsMigrations
+		_, err = db.Exec("ALTER TABLE " + m.table + " ADD COLUMN " + m.column + " " + m.definition)
+		if err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", m.table, m.column, err)
+		}
+	}
+	return nil
+}
+
+func columnExists(db *sql.DB, table, column string) (bool, error) {
+	// #nosec G202 - table comes from requestsMigrations
+	rows, err := db.Query("PRAGMA table_info(" + table + ")")
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			ctype     string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &ctype, &notNull, &dfltValue, &pk); err != nil {
+			return false, fmt.Errorf("failed to scan table info: %w", err)
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
 func CreateTables() (err error) {
 	// Requests DB
-	err1 := initDB(buildSqliteDsn(ReqDBPath, pragmas), CreateRequestsTable)
-	err2 := initDB(buildSqliteDsn(banDBPath, pragmas), CreateBansTable)
+	err1 := initDB(
+		buildSqliteDsn(ReqDBPath, pragmas),
+		CreateRequestsTable,
+		requestsMigrations,
+		CreateRequestsIndexes,
+	)
+	err2 := initDB(buildSqliteDsn(banDBPath, pragmas), CreateBansTable+CreatePositionsTable, nil, "")
 
 	return errors.Join(err1, err2)
 }
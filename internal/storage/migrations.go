@@ -8,6 +8,8 @@ CREATE TABLE IF NOT EXISTS requests (
 	path TEXT,
 	method TEXT,
 	status TEXT,
+	rule TEXT NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT '',
 	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
 
@@ -17,6 +19,13 @@ CREATE INDEX IF NOT EXISTS idx_requests_status ON requests(status);
 CREATE INDEX IF NOT EXISTS idx_requests_created_at ON requests(created_at);
 `
 
+// CreateRequestsIndexes holds indexes on columns that older databases only
+// get through requestsMigrations, so it must run after them.
+const CreateRequestsIndexes = `
+CREATE INDEX IF NOT EXISTS idx_requests_ip_rule_created_at
+	ON requests(ip, rule, created_at);
+`
+
 const CreateBansTable = `
 CREATE TABLE IF NOT EXISTS bans (
 	id INTEGER PRIMARY KEY,
@@ -27,4 +36,52 @@ CREATE TABLE IF NOT EXISTS bans (
 );
 
 CREATE INDEX IF NOT EXISTS idx_bans_ip ON bans(ip);
+
+-- Bans recorded in monitor mode, which never reach the firewall.
+CREATE TABLE IF NOT EXISTS monitor_bans (
+	id INTEGER PRIMARY KEY,
+	ip TEXT UNIQUE NOT NULL,
+	reason TEXT,
+	banned_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	expired_at DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS ban_history (
+	id INTEGER PRIMARY KEY,
+	ip TEXT NOT NULL,
+	event TEXT NOT NULL,
+	rule TEXT,
+	source TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	expired_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_ban_history_ip ON ban_history(ip);
+CREATE INDEX IF NOT EXISTS idx_ban_history_created_at ON ban_history(created_at);
 `
+
+// CreatePositionsTable stores how far each log source has been read. It lives
+// in the bans database because, unlike requests, it must survive cleanup.
+const CreatePositionsTable = `
+CREATE TABLE IF NOT EXISTS positions (
+	source TEXT PRIMARY KEY,
+	inode INTEGER NOT NULL DEFAULT 0,
+	offset INTEGER NOT NULL DEFAULT 0,
+	cursor TEXT NOT NULL DEFAULT '',
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+// columnMigration adds a column that was introduced after a table was first
+// created. CREATE TABLE IF NOT EXISTS leaves existing tables untouched, so
+// these are applied to databases created by older releases.
+type columnMigration struct {
+	table      string
+	column     string
+	definition string
+}
+
+var requestsMigrations = []columnMigration{
+	{table: "requests", column: "rule", definition: "TEXT NOT NULL DEFAULT ''"},
+	{table: "requests", column: "user_agent", definition: "TEXT NOT NULL DEFAULT ''"},
+}
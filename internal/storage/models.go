@@ -1,5 +1,7 @@
 package storage
 
+import "time"
+
 type LogEntry struct {
 	ID        int    `db:"id"`
 	Service   string `db:"service"`
@@ -7,7 +9,56 @@ type LogEntry struct {
 	Path      string `db:"path"`
 	Status    string `db:"status"`
 	Method    string `db:"method"`
+	Rule      string `db:"rule"`
+	UserAgent string `db:"user_agent"`
+	CreatedAt string `db:"created_at"`
+	// Time is when the line was logged, zero if it had no timestamp.
+	Time time.Time `db:"-"`
+}
+
+// When returns the time the entry was logged, or the current time if the
+// line had no timestamp.
+func (e *LogEntry) When() time.Time {
+	if e.Time.IsZero() {
+		return time.Now()
+	}
+	return e.Time
+}
+
+// Position is the point up to which a log source has been read. Files are
+// identified by inode and byte offset, journald units by their cursor.
+type Position struct {
+	Source string `db:"source"`
+	Inode  uint64 `db:"inode"`
+	Offset int64  `db:"offset"`
+	Cursor string `db:"cursor"`
+}
+
+// Ban history events and the component that caused them.
+const (
+	EventBan   = "ban"
+	EventUnban = "unban"
+
+	SourceJudge   = "judge"
+	SourceManual  = "manual"
+	SourceRestore = "restore"
+	SourceMonitor = "monitor"
+)
+
+// ActiveBan is a ban that has not expired yet.
+type ActiveBan struct {
+	IP        string
+	ExpiresAt time.Time
+}
+
+type BanEvent struct {
+	ID        int    `db:"id"`
+	IP        string `db:"ip"`
+	Event     string `db:"event"`
+	Rule      string `db:"rule"`
+	Source    string `db:"source"`
 	CreatedAt string `db:"created_at"`
+	Expired   string `db:"expired_at"`
 }
 
 type Ban struct {
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/d3m0k1d/BanForge/internal/logger"
+	"github.com/d3m0k1d/BanForge/internal/metrics"
+	_ "modernc.org/sqlite"
+)
+
+// PositionStore persists read positions of log sources across restarts.
+type PositionStore struct {
+	logger *logger.Logger
+	db     *sql.DB
+}
+
+func NewPositionStore() (*PositionStore, error) {
+	return NewPositionStoreWithDBPath(banDBPath)
+}
+
+func NewPositionStoreWithDBPath(dbPath string) (*PositionStore, error) {
+	db, err := sql.Open(
+		"sqlite",
+		buildSqliteDsn(dbPath, pragmas),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &PositionStore{
+		logger: logger.New(false),
+		db:     db,
+	}, nil
+}
+
+func (s *PositionStore) CreateTable() error {
+	_, err := s.db.Exec(CreatePositionsTable)
+	if err != nil {
+		return err
+	}
+	metrics.IncDBOperation("create_table", "positions")
+	return nil
+}
+
+// Load returns the stored position of source. ok is false if source has not
+// been saved yet.
+func (s *PositionStore) Load(source string) (pos Position, ok bool, err error) {
+	err = s.db.QueryRow(
+		"SELECT source, inode, offset, cursor FROM positions WHERE source = ?",
+		source,
+	).Scan(&pos.Source, &pos.Inode, &pos.Offset, &pos.Cursor)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Position{}, false, nil
+	}
+	if err != nil {
+		metrics.IncError()
+		return Position{}, false, fmt.Errorf("failed to load position of %s: %w", source, err)
+	}
+	metrics.IncDBOperation("select", "positions")
+	return pos, true, nil
+}
+
+// Save stores pos, replacing any earlier position of the same source.
+func (s *PositionStore) Save(pos Position) error {
+	_, err := s.db.Exec(
+		`INSERT INTO positions (source, inode, offset, cursor, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(source) DO UPDATE SET
+			inode = excluded.inode,
+			offset = excluded.offset,
+			cursor = excluded.cursor,
+			updated_at = excluded.updated_at`,
+		pos.Source,
+		pos.Inode,
+		pos.Offset,
+		pos.Cursor,
+		time.Now().Format(time.RFC3339),
+	)
+	if err != nil {
+		metrics.IncError()
+		return fmt.Errorf("failed to save position of %s: %w", pos.Source, err)
+	}
+	metrics.IncDBOperation("upsert", "positions")
+	return nil
+}
+
+func (s *PositionStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPositionStore(t *testing.T) {
+	store, err := NewPositionStoreWithDBPath(filepath.Join(t.TempDir(), "bans_test.db"))
+	if err != nil {
+		t.Fatalf("NewPositionStoreWithDBPath() error = %v", err)
+	}
+	defer store.Close()
+
+	if err := store.CreateTable(); err != nil {
+		t.Fatalf("CreateTable() error = %v", err)
+	}
+
+	if _, ok, err := store.Load("file:/var/log/nginx/access.log"); err != nil || ok {
+		t.Fatalf("Load() of unknown source = %v, %v; want not found", ok, err)
+	}
+
+	positions := []Position{
+		{Source: "file:/var/log/nginx/access.log", Inode: 42, Offset: 1024},
+		{Source: "file:/var/log/nginx/access.log", Inode: 43, Offset: 10},
+		{Source: "journald:sshd", Cursor: "s=abc;i=1"},
+	}
+	for _, want := range positions {
+		if err := store.Save(want); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		got, ok, err := store.Load(want.Source)
+		if err != nil || !ok {
+			t.Fatalf("Load() = %v, %v", ok, err)
+		}
+		if got != want {
+			t.Errorf("Load() = %+v, want %+v", got, want)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package storage
 
 import (
 	"database/sql"
+	"fmt"
+	"time"
 
 	"github.com/d3m0k1d/BanForge/internal/logger"
 	"github.com/d3m0k1d/BanForge/internal/metrics"
@@ -56,18 +58,38 @@ func (r *RequestReader) Close() error {
 	return r.db.Close()
 }
 
-func (r *RequestReader) IsMaxRetryExceeded(ip string, maxRetry int) (bool, error) {
-	var count int
-	if maxRetry == 0 {
-		return true, nil
-	}
-	err := r.db.QueryRow("SELECT COUNT(*) FROM requests WHERE ip = ?", ip).Scan(&count)
+// RecentHits returns the rule hits stored since the given time, oldest first.
+// The judge uses them to warm up its in-memory retry counter on startup.
+func (r *RequestReader) RecentHits(since time.Time) ([]LogEntry, error) {
+	rows, err := r.db.Query(
+		"SELECT ip, rule, created_at FROM requests WHERE rule != '' AND created_at >= ? ORDER BY created_at",
+		since.Format(time.RFC3339),
+	)
 	if err != nil {
-		r.logger.Error("error query count: " + err.Error())
+		r.logger.Error("Failed to get recent hits", "error", err)
 		metrics.IncError()
-		return false, err
+		return nil, fmt.Errorf("failed to get recent hits: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			r.logger.Error("Failed to close rows", "error", err)
+		}
+	}()
+
+	var hits []LogEntry
+	for rows.Next() {
+		var hit LogEntry
+		if err := rows.Scan(&hit.IP, &hit.Rule, &hit.CreatedAt); err != nil {
+			metrics.IncError()
+			return nil, fmt.Errorf("failed to scan recent hit: %w", err)
+		}
+		hits = append(hits, hit)
 	}
-	r.logger.Info("Current request count for IP", "ip", ip, "count", count, "maxRetry", maxRetry)
-	metrics.IncDBOperation("select", "requests")
-	return count >= maxRetry, nil
+	if err := rows.Err(); err != nil {
+		metrics.IncError()
+		return nil, fmt.Errorf("failed to iterate recent hits: %w", err)
+	}
+
+	metrics.IncDBOperation("select_recent", "requests")
+	return hits, nil
 }
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/d3m0k1d/BanForge/internal/logger"
+)
+
+func TestInitDB_MigratesRequestsRuleColumn(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "requests.db")
+	dsn := buildSqliteDsn(dbPath, pragmas)
+
+	const legacySchema = `
+CREATE TABLE requests (
+	id INTEGER PRIMARY KEY,
+	service TEXT NOT NULL,
+	ip TEXT NOT NULL,
+	path TEXT,
+	method TEXT,
+	status TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO requests (service, ip) VALUES ('nginx', '192.0.2.10');
+`
+	if err := initDB(dsn, legacySchema, nil, ""); err != nil {
+		t.Fatalf("failed to create legacy schema: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := initDB(dsn, CreateRequestsTable, requestsMigrations, CreateRequestsIndexes); err != nil {
+			t.Fatalf("initDB() run %d error = %v", i+1, err)
+		}
+	}
+
+	writer, err := NewRequestWriterWithDBPath(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	var rule, userAgent string
+	err = writer.db.QueryRow("SELECT rule, user_agent FROM requests WHERE ip = '192.0.2.10'").
+		Scan(&rule, &userAgent)
+	if err != nil {
+		t.Fatalf("failed to read migrated columns: %v", err)
+	}
+	if rule != "" || userAgent != "" {
+		t.Errorf("migrated rule, user_agent = %q, %q, want empty", rule, userAgent)
+	}
+}
+
+func insertRuleTestRequest(
+	t *testing.T,
+	writer *RequestWriter,
+	ip string,
+	rule string,
+	createdAt time.Time,
+) {
+	t.Helper()
+
+	_, err := writer.db.Exec(
+		`INSERT INTO requests (service, ip, path, method, status, rule, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		"test", ip, "/", "GET", "401", rule, createdAt.Format(time.RFC3339),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRequestReader_RecentHits(t *testing.T) {
+	writer := newCleanupTestWriter(t)
+	reader := &RequestReader{logger: logger.New(false), db: writer.db}
+	now := time.Now()
+
+	insertRuleTestRequest(t, writer, "192.0.2.10", "wp-login", now.Add(-2*time.Hour))
+	insertRuleTestRequest(t, writer, "192.0.2.11", "404-flood", now.Add(-time.Minute))
+	insertRuleTestRequest(t, writer, "192.0.2.10", "wp-login", now.Add(-5*time.Minute))
+	insertRuleTestRequest(t, writer, "192.0.2.12", "", now.Add(-time.Minute))
+
+	hits, err := reader.RecentHits(now.Add(-10 * time.Minute))
+	if err != nil {
+		t.Fatalf("RecentHits() error = %v", err)
+	}
+	want := []string{"192.0.2.10 wp-login", "192.0.2.11 404-flood"}
+	if len(hits) != len(want) {
+		t.Fatalf("RecentHits() = %+v, want %v", hits, want)
+	}
+	for i, hit := range hits {
+		if got := hit.IP + " " + hit.Rule; got != want[i] {
+			t.Errorf("hit %d = %q, want %q", i, got, want[i])
+		}
+	}
+}
@@ -40,7 +40,7 @@ func WriteReq(db *RequestWriter, resultCh <-chan *LogEntry) {
 			}()
 
 			stmt, err := tx.Prepare(
-				"INSERT INTO requests (service, ip, path, method, status, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+				"INSERT INTO requests (service, ip, path, method, status, rule, user_agent, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
 			)
 			if err != nil {
 				err = fmt.Errorf("failed to prepare statement: %w", err)
@@ -59,7 +59,10 @@ func WriteReq(db *RequestWriter, resultCh <-chan *LogEntry) {
 					entry.Path,
 					entry.Method,
 					entry.Status,
-					time.Now().Format(time.RFC3339),
+					entry.Rule,
+					entry.UserAgent,
+					// In local time, like the bounds created_at is compared with.
+					entry.When().Local().Format(time.RFC3339),
 				)
 				if err != nil {
 					db.logger.Error(fmt.Errorf("failed to insert entry: %w", err).Error())
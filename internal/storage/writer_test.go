@@ -33,11 +33,11 @@ func TestWrite_BatchInsert(t *testing.T) {
 	}()
 
 	entries := []*LogEntry{
-		{Service: "service1", IP: "192.168.1.1", Path: "/path1", Method: "GET", Status: "200"},
-		{Service: "service2", IP: "192.168.1.2", Path: "/path2", Method: "POST", Status: "404"},
-		{Service: "service3", IP: "192.168.1.3", Path: "/path3", Method: "PUT", Status: "500"},
-		{Service: "service4", IP: "192.168.1.4", Path: "/path4", Method: "DELETE", Status: "200"},
-		{Service: "service5", IP: "192.168.1.5", Path: "/path5", Method: "GET", Status: "301"},
+		{Service: "service1", IP: "192.168.1.1", Path: "/path1", Method: "GET", Status: "200", Rule: "rule1", UserAgent: "curl/8.5.0"},
+		{Service: "service2", IP: "192.168.1.2", Path: "/path2", Method: "POST", Status: "404", Rule: "rule2"},
+		{Service: "service3", IP: "192.168.1.3", Path: "/path3", Method: "PUT", Status: "500", Rule: "rule3"},
+		{Service: "service4", IP: "192.168.1.4", Path: "/path4", Method: "DELETE", Status: "200", Rule: "rule4"},
+		{Service: "service5", IP: "192.168.1.5", Path: "/path5", Method: "GET", Status: "301", Rule: "rule5"},
 	}
 
 	for _, entry := range entries {
@@ -55,7 +55,7 @@ func TestWrite_BatchInsert(t *testing.T) {
 	if count != len(entries) {
 		t.Errorf("Expected %d entries, got %d", len(entries), count)
 	}
-	rows, err := writer.db.Query("SELECT service, ip, path, method, status FROM requests ORDER BY id")
+	rows, err := writer.db.Query("SELECT service, ip, path, method, status, rule, user_agent FROM requests ORDER BY id")
 	if err != nil {
 		t.Fatalf("Failed to query requests: %v", err)
 	}
@@ -63,8 +63,8 @@ func TestWrite_BatchInsert(t *testing.T) {
 
 	i := 0
 	for rows.Next() {
-		var service, ip, path, method, status string
-		err := rows.Scan(&service, &ip, &path, &method, &status)
+		var service, ip, path, method, status, rule, userAgent string
+		err := rows.Scan(&service, &ip, &path, &method, &status, &rule, &userAgent)
 		if err != nil {
 			t.Fatalf("Failed to scan row: %v", err)
 		}
@@ -89,6 +89,12 @@ func TestWrite_BatchInsert(t *testing.T) {
 		if status != expected.Status {
 			t.Errorf("Expected status %s, got %s", expected.Status, status)
 		}
+		if rule != expected.Rule {
+			t.Errorf("Expected rule %s, got %s", expected.Rule, rule)
+		}
+		if userAgent != expected.UserAgent {
+			t.Errorf("Expected user agent %s, got %s", expected.UserAgent, userAgent)
+		}
 
 		i++
 	}
@@ -277,8 +283,57 @@ func TestWrite_ChannelClosed(t *testing.T) {
 	}
 }
 
+func TestWrite_CreatedAtFromEntryTime(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "requests_test.db")
+	writer, err := NewRequestWriterWithDBPath(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create RequestWriter: %v", err)
+	}
+	defer writer.Close()
+	if err := writer.CreateTable(); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	logged := time.Date(2026, time.March, 7, 10, 4, 5, 0, time.FixedZone("CET", 3600))
+	resultCh := make(chan *LogEntry, 2)
+	resultCh <- &LogEntry{Service: "nginx", IP: "192.168.1.1", Time: logged}
+	resultCh <- &LogEntry{Service: "nginx", IP: "192.168.1.2"}
+	close(resultCh)
+	before := time.Now().Add(-time.Second)
+	WriteReq(writer, resultCh)
+
+	rows, err := writer.db.Query("SELECT ip, created_at FROM requests ORDER BY id")
+	if err != nil {
+		t.Fatalf("Failed to query requests: %v", err)
+	}
+	defer rows.Close()
+
+	var got []string
+	for rows.Next() {
+		var ip, createdAt string
+		if err := rows.Scan(&ip, &createdAt); err != nil {
+			t.Fatalf("Failed to scan row: %v", err)
+		}
+		got = append(got, createdAt)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 rows, got %d", len(got))
+	}
+	// Stored in local time, so string comparisons with time.Now() bounds work.
+	if want := logged.Local().Format(time.RFC3339); got[0] != want {
+		t.Errorf("created_at = %s, want the log time %s", got[0], want)
+	}
+	inserted, err := time.Parse(time.RFC3339, got[1])
+	if err != nil {
+		t.Fatalf("created_at %q: %v", got[1], err)
+	}
+	if inserted.Before(before) {
+		t.Errorf("created_at = %s, want the insert time for an entry without a timestamp", got[1])
+	}
+}
+
 func NewRequestWriterWithDBPath(dbPath string) (*RequestWriter, error) {
-	db, err := sql.Open("sqlite", dbPath+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(30000)&_pragma=synchronous(NORMAL)")
+	db, err := sql.Open("sqlite", buildSqliteDsn(dbPath, pragmas))
 	if err != nil {
 		return nil, err
 	}